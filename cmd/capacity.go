@@ -0,0 +1,134 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rocketk/httptester/task"
+	"github.com/spf13/cobra"
+)
+
+var (
+	capacityMode      string
+	capacityStart     float64
+	capacityStep      float64
+	capacityMax       float64
+	capacityPrecision float64
+	stepDuration      time.Duration
+	maxInFlight       int
+	limits            []string
+)
+
+// capacityCmd represents the capacity command
+var capacityCmd = &cobra.Command{
+	Use:   "capacity",
+	Short: "Find the max sustainable load",
+	Long: `Find the max sustainable load by running the test case repeatedly with increasing load,
+until one of the limits is breached, and then narrowing in with a binary search. For example:
+
+httptester capacity -u http://localhost:1234/users --start 10 --step 10 --max 500 --limit 'p99<300ms' --limit 'error-rate<1%'
+httptester capacity -u http://localhost:1234/users --mode rate --start 100 --step 100 --max 5000 -c 1000 --limit 'p99<300ms'
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		applyCurl(cmd)
+		if url == "" {
+			panic("url is required")
+		}
+		if capacityMode != "concurrency" && capacityMode != "rate" {
+			panic("the mode should be either 'concurrency' or 'rate'")
+		}
+		criteria := make([]task.Criterion, 0, len(limits))
+		for _, limit := range limits {
+			criterion, err := task.ParseCriterion(limit)
+			if err != nil {
+				panic(err)
+			}
+			criteria = append(criteria, criterion)
+		}
+		assertions := buildAssertions()
+		search := &task.CapacitySearch{
+			Start:     capacityStart,
+			Step:      capacityStep,
+			Max:       capacityMax,
+			Precision: capacityPrecision,
+			Criteria:  criteria,
+			Run: func(load float64) *task.SimpleListener {
+				taskDef := task.TaskDef{
+					Concurrency:   int(load),
+					Duration:      stepDuration,
+					Timeout:       timeout,
+					URL:           url,
+					Method:        method,
+					Headers:       headers,
+					Body:          body,
+					TimeUnit:      timeunit,
+					DisableBar:    true,
+					DisableReport: true,
+					PrintError:    printError,
+					Insecure:      insecure,
+				}
+				if capacityMode == "rate" {
+					taskDef.Concurrency = maxInFlight
+					taskDef.Rate = load
+				}
+				plan := &task.Plan{
+					TaskDef:    taskDef,
+					Assertions: assertions,
+				}
+				plan.Start()
+				return plan.Result()
+			},
+			OnTrial: func(trial task.CapacityTrial) {
+				fmt.Printf("%s: %g\trequests: %d\t", capacityMode, trial.Load, trial.Result.TotalCount())
+				for i, criterion := range criteria {
+					fmt.Printf("%s: %s\t", criterion.Metric, criterion.Format(trial.Observed[i]))
+				}
+				if trial.Passed {
+					fmt.Println("passed")
+				} else {
+					fmt.Println("breached")
+				}
+			},
+		}
+		if err := search.Validate(); err != nil {
+			panic(err)
+		}
+		fmt.Printf("-- Capacity Search --\nLimits: %s\tStep Duration: %s\n", limits, stepDuration)
+		best, found := search.Find()
+		fmt.Println()
+		if !found {
+			fmt.Printf("no %s met the limits\n", capacityMode)
+			return
+		}
+		fmt.Printf("max sustainable %s: %g\n", capacityMode, best)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(capacityCmd)
+
+	capacityCmd.Flags().StringVarP(&capacityMode, "mode", "", "concurrency", "what to increase, 'concurrency' for the number of goroutines or 'rate' for the arrival rate in requests per second")
+	capacityCmd.Flags().Float64VarP(&capacityStart, "start", "", 10, "the load of the first run")
+	capacityCmd.Flags().Float64VarP(&capacityStep, "step", "", 10, "how much the load increases for each run until a limit is breached")
+	capacityCmd.Flags().Float64VarP(&capacityMax, "max", "", 1000, "the max load to try")
+	capacityCmd.Flags().Float64VarP(&capacityPrecision, "precision", "", 1, "the binary search stops once the interval is not larger than the precision")
+	capacityCmd.Flags().DurationVarP(&stepDuration, "step-duration", "", 30*time.Second, "how long each run takes")
+	capacityCmd.Flags().IntVarP(&maxInFlight, "concurrency", "c", 100, "the maximum of in-flight requests in the 'rate' mode")
+	capacityCmd.Flags().StringArrayVarP(&limits, "limit", "", []string{}, "a limit the load must meet, e.g. 'p99<300ms', 'mean<100ms', 'max<1s', 'error-rate<1%' or 'throughput>=1000'")
+	addRequestFlags(capacityCmd)
+}
@@ -0,0 +1,210 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rocketk/httptester/convert"
+	"github.com/rocketk/httptester/task"
+	"github.com/spf13/cobra"
+)
+
+var (
+	outputFile   string
+	scenarioName string
+	harOptions   convert.HarOptions
+	// postmanEnvironment is the environment file of the postman collection
+	postmanEnvironment string
+	postmanOptions     convert.PostmanOptions
+	jmxOptions         convert.JMXOptions
+)
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert requests of other tools into a scenario file",
+	Long:  `Convert requests of other tools into a scenario file, which can be run by 'httptester run -f'`,
+}
+
+// convertCurlCmd represents the convert curl command
+var convertCurlCmd = &cobra.Command{
+	Use:   "curl [command]",
+	Short: "Convert curl commands into a scenario file",
+	Long: `Convert curl commands into a scenario file, each command becomes a step in order.
+The commands are read from the standard input if the argument is missing or '-', they are separated by new lines, ';' or '&&'. For example:
+
+httptester convert curl "curl -X POST http://localhost:1234/users -H 'Content-Type: application/json' -d '{\"name\": \"tester\"}'"
+httptester convert curl -o scenario.yaml < requests.sh
+`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var text string
+		if len(args) == 0 || args[0] == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				panic(err)
+			}
+			text = string(data)
+		} else {
+			text = args[0]
+		}
+		requests, err := convert.ParseCurls(text)
+		if err != nil {
+			panic(err)
+		}
+		writeScenario(convert.ScenarioOf(scenarioName, requests))
+	},
+}
+
+// convertHarCmd represents the convert har command
+var convertHarCmd = &cobra.Command{
+	Use:   "har <file>",
+	Short: "Convert a HAR file into a scenario file",
+	Long: `Convert a HAR file recorded by a browser or a proxy into a scenario file, each request becomes a step in the order they started.
+The pause between the end of a request and the start of the next one becomes the delay of the next step, so that the think time of the user is kept.
+The static assets are dropped by their content types by default. For example:
+
+httptester convert har session.har -o scenario.yaml
+httptester convert har session.har --host api.example.com --method POST --method PUT -o scenario.yaml
+httptester convert har session.har --content-type json --no-delays -o scenario.yaml
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := convert.LoadHAR(args[0], harOptions)
+		if err != nil {
+			panic(err)
+		}
+		s.Name = scenarioName
+		writeScenario(s)
+	},
+}
+
+// convertPostmanCmd represents the convert postman command
+var convertPostmanCmd = &cobra.Command{
+	Use:   "postman <collection>",
+	Short: "Convert a Postman collection into a scenario file",
+	Long: `Convert a Postman collection v2.1 exported in JSON into a scenario file, each request becomes a step in the order of the collection.
+The variables are replaced by the values of the environment file or the collection, and the dynamic variables like {{$guid}} by the template functions.
+A variable set to a value of the response by a test script, like 'pm.environment.set("token", pm.response.json().token)', is extracted by the step,
+and the variables without a value are left as '${name}' to be set by the data files.
+The auth blocks become headers, and the tests like 'pm.response.to.have.status(200)' become the status assertions. For example:
+
+httptester convert postman users.postman_collection.json -e staging.postman_environment.json -o scenario.yaml
+httptester convert postman users.postman_collection.json --folder Users -o scenario.yaml
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if postmanEnvironment != "" {
+			env, err := convert.LoadPostmanEnvironment(postmanEnvironment)
+			if err != nil {
+				panic(err)
+			}
+			postmanOptions.Environment = env
+		}
+		s, err := convert.LoadPostman(args[0], postmanOptions)
+		if err != nil {
+			panic(err)
+		}
+		if scenarioName != "" {
+			s.Name = scenarioName
+		}
+		writeScenario(s)
+	},
+}
+
+// convertJmxCmd represents the convert jmx command
+var convertJmxCmd = &cobra.Command{
+	Use:   "jmx <file>",
+	Short: "Convert a JMeter test plan into a scenario file",
+	Long: `Convert the common subset of a JMeter test plan into a scenario file: the HTTP samplers of a thread group become the steps in order,
+with the headers of the HTTP header managers, the delays of the constant timers, and the response assertions and the JSON assertions in their scope.
+The user defined variables are replaced by their values, and the common functions like ${__UUID()} and ${__Random(1,10)} by the template functions.
+The threads, the loops and the ramp-up of the thread group, and the CSV data set, are printed as the flags of 'httptester run'.
+The elements which are not converted, or converted partially, are listed as the warnings. For example:
+
+httptester convert jmx users.jmx -o scenario.yaml
+httptester convert jmx users.jmx --thread-group Admins -o admins.yaml
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		p, err := convert.LoadJMX(args[0], jmxOptions)
+		if err != nil {
+			panic(err)
+		}
+		if scenarioName != "" {
+			p.Scenario.Name = scenarioName
+		}
+		writeScenario(p.Scenario)
+		for _, w := range p.Warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		file := outputFile
+		if file == "" {
+			file = "scenario.yaml"
+		}
+		runArgs := p.RunArgs(file)
+		for i, arg := range runArgs {
+			if strings.ContainsAny(arg, " '\"$") {
+				runArgs[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+			}
+		}
+		fmt.Fprintf(os.Stderr, "run it with: httptester %s\n", strings.Join(runArgs, " "))
+	},
+}
+
+// addHarFlags adds the filters of the HAR file, the prefix avoids the flags of the request in the run command
+func addHarFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().StringArrayVarP(&harOptions.Hosts, prefix+"host", "", []string{}, "keep the requests to the host or its subdomains only, it can be repeated")
+	cmd.Flags().StringArrayVarP(&harOptions.Methods, prefix+"method", "", []string{}, "keep the requests of the method only, it can be repeated")
+	cmd.Flags().StringArrayVarP(&harOptions.ContentTypes, prefix+"content-type", "", []string{}, "keep the responses whose content type contains it only, e.g. 'json', it can be repeated")
+	cmd.Flags().StringArrayVarP(&harOptions.ExcludeContentTypes, prefix+"exclude-content-type", "", convert.DefaultStaticContentTypes, "drop the responses whose content type contains it, it can be repeated, the static assets are dropped by default")
+	cmd.Flags().BoolVarP(&harOptions.IgnoreDelays, prefix+"no-delays", "", false, "send the requests one after another without the pauses between them")
+}
+
+// writeScenario writes the scenario to the output file, or the standard output if it is not set
+func writeScenario(s *task.Scenario) {
+	w := os.Stdout
+	if outputFile != "" {
+		f, err := os.Create(outputFile)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := convert.WriteScenario(w, s); err != nil {
+		panic(err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+	convertCmd.AddCommand(convertCurlCmd)
+	convertCmd.AddCommand(convertHarCmd)
+	convertCmd.AddCommand(convertPostmanCmd)
+	convertCmd.AddCommand(convertJmxCmd)
+
+	convertCmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "", "the scenario file to write, it is written to the standard output if it is not set")
+	convertCmd.PersistentFlags().StringVarP(&scenarioName, "name", "", "", "the name of the scenario")
+	addHarFlags(convertHarCmd, "")
+	convertPostmanCmd.Flags().StringVarP(&postmanEnvironment, "environment", "e", "", "the environment file exported by Postman, its values override the variables of the collection")
+	convertJmxCmd.Flags().StringVarP(&jmxOptions.ThreadGroup, "thread-group", "", "", "the name of the thread group to convert, the first enabled one is converted if it is not set")
+	convertPostmanCmd.Flags().StringArrayVarP(&postmanOptions.Folders, "folder", "", []string{}, "keep the requests in the folder only, it can be repeated")
+}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2023 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/rocketk/httptester/convert"
+	"github.com/spf13/cobra"
+)
+
+var openAPIOptions convert.OpenAPIOptions
+
+// openAPICmd represents the openapi command
+var openAPICmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "Work with OpenAPI specifications",
+	Long:  `Work with OpenAPI specifications`,
+}
+
+// openAPIGenCmd represents the openapi gen command
+var openAPIGenCmd = &cobra.Command{
+	Use:   "gen <spec>",
+	Short: "Generate a scenario from an OpenAPI 3 specification",
+	Long: `Generate a scenario with a step for each operation of an OpenAPI 3 specification in YAML or JSON, from a file or a url.
+The parameters and the bodies are filled from the examples, or the fake values of their schemas like '{{randInt 1 100}}',
+so that each request is different. The success status codes declared by the responses are asserted.
+If a POST to a collection like '/users' returns an id, the operations on its items like '/users/{id}' use the created one. For example:
+
+httptester openapi gen http://localhost:1234/openapi.yaml -o scenario.yaml
+httptester openapi gen spec.yaml --server https://staging.example.com/api --tag users -o scenario.yaml
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := convert.LoadOpenAPI(args[0], openAPIOptions)
+		if err != nil {
+			panic(err)
+		}
+		s.Name = scenarioName
+		writeScenario(s)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(openAPICmd)
+	openAPICmd.AddCommand(openAPIGenCmd)
+
+	openAPIGenCmd.Flags().StringVarP(&outputFile, "output", "o", "", "the scenario file to write, it is written to the standard output if it is not set")
+	openAPIGenCmd.Flags().StringVarP(&scenarioName, "name", "", "", "the name of the scenario")
+	openAPIGenCmd.Flags().StringVarP(&openAPIOptions.Server, "server", "", "", "the base url of the requests, the first of the servers of the specification is used if it is not set")
+	openAPIGenCmd.Flags().StringArrayVarP(&openAPIOptions.Tags, "tag", "", []string{}, "keep the operations of the tag only, it can be repeated")
+}
@@ -16,18 +16,48 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/rocketk/httptester/convert"
 	"github.com/rocketk/httptester/task"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
-	loop        int
-	concurrency int
-	timeout     time.Duration
+	loop               int
+	concurrency        int
+	requests           int
+	duration           time.Duration
+	rate               string
+	stages             string
+	adaptive           task.AdaptiveDef
+	warmup             time.Duration
+	warmupRequests     int
+	printWarmup        bool
+	thinkTime          string
+	pacing             time.Duration
+	interval           time.Duration
+	printTimeline      bool
+	percentiles        string
+	exactLatency       bool
+	histogramPrecision int
+	latencyMode        string
+	scenarioFile       string
+	mixFile            string
+	harFile            string
+	seed               int64
+	dataFile           string
+	dataMode           string
+	dataExhausted      string
+	timeout            time.Duration
 	// keepAlive             bool
 	url                   string
 	method                string
@@ -41,6 +71,7 @@ var (
 	disableBar            bool
 	printError            bool
 	insecure              bool
+	fromCurl              string
 )
 
 // runCmd represents the run command
@@ -51,41 +82,138 @@ var runCmd = &cobra.Command{
 
 httptester run --loop 10 --concurrency 10 --timeout 10s
 httptester run --loop 10 --concurrency 100 --timeout 500ms --keep-alive false 
+httptester run --duration 10m --concurrency 200
+httptester run --duration 10m --rate 500/s --concurrency 1000
+httptester run --concurrency 7 --requests 1000
+httptester run --concurrency 10 --stages 2m:500,10m:500,2m:0
+httptester run --duration 10m --concurrency 10 --target-latency 200ms --target-percentile 95
+httptester run --duration 10m --concurrency 50 -f scenario.yaml
+httptester run --duration 10m --concurrency 50 --mix mix.yaml --seed 42
+httptester run --duration 10m --concurrency 50 --har session.har --har-host api.example.com
+httptester run --loop 100 --from-curl "curl -X POST http://localhost:1234/users -H 'Content-Type: application/json' -d '{\"name\": \"tester\"}'"
+httptester run --requests 1000 --concurrency 10 --data users.csv -u 'http://localhost:1234/users/${id}'
+httptester run --loop 100 --method POST -u http://localhost:1234/users -b '{"name": "user-{{seq}}", "token": "{{uuid}}", "age": {{randInt 18 60}}}'
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		if url == "" {
+		applyCurl(cmd)
+		var scenario *task.Scenario
+		var mix *task.Mix
+		sources := 0
+		for _, source := range []string{scenarioFile, mixFile, harFile} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources > 1 {
+			panic("only 1 of --file, --mix and --har can be used")
+		}
+		if sources > 0 && url != "" {
+			panic("--url can not be used together with --file, --mix or --har")
+		}
+		// the steps and the requests of the files have their own assertions
+		if sources > 0 && (assertStatusCodes != "" || assertJSONExpression != "" || assertRegexExpression != "") {
+			panic("--assert-* can not be used together with --file, --mix or --har, use the assert of each step or request instead")
+		}
+		var err error
+		if scenarioFile != "" {
+			if scenario, err = task.LoadScenario(scenarioFile); err != nil {
+				panic(err)
+			}
+		} else if harFile != "" {
+			if scenario, err = convert.LoadHAR(harFile, harOptions); err != nil {
+				panic(err)
+			}
+			if err = scenario.Validate(); err != nil {
+				panic(err)
+			}
+		} else if mixFile != "" {
+			if mix, err = task.LoadMix(mixFile); err != nil {
+				panic(err)
+			}
+		} else if url == "" {
 			panic("url is required")
 		}
+		for _, value := range append([]string{url, body}, headers...) {
+			if err := task.ValidateTemplate(value); err != nil {
+				panic(err)
+			}
+		}
 		// fmt.Printf("keepAlive: %t\n", keepAlive)
-		assertions := make([]task.Assertion, 0, 8)
-		if len(assertStatusCodes) > 0 {
-			intAssertStatusCodes := make([]int, 0, 8)
-			for _, code := range strings.Split(assertStatusCodes, " ") {
-				codeInt, err := strconv.Atoi(code)
-				if err != nil {
-					panic(err)
-				}
-				intAssertStatusCodes = append(intAssertStatusCodes, codeInt)
+		ratePerSecond, err := task.ParseRate(rate)
+		if err != nil {
+			panic(err)
+		}
+		think, err := task.ParseThinkTime(thinkTime)
+		if err != nil {
+			panic(err)
+		}
+		var feeder *task.Feeder
+		if dataFile != "" {
+			if feeder, err = task.LoadFeeder(dataFile, dataMode, dataExhausted); err != nil {
+				panic(err)
 			}
-			assertions = append(assertions, &task.StatusCodeAssertion{
-				ExpectedCodes: intAssertStatusCodes,
-			})
 		}
-		if len(assertJSONExpression) > 0 {
-			assertions = append(assertions, &task.JsonPathAssertion{
-				Expression: assertJSONExpression,
-			})
+		percentileList, err := task.ParsePercentiles(percentiles)
+		if err != nil {
+			panic(err)
+		}
+		stageList, err := loadStages()
+		if err != nil {
+			panic(err)
+		}
+		if ratePerSecond > 0 && len(stageList) > 0 {
+			panic("--rate and --stages can not be used together")
+		}
+		// -c is the limit of the requests in flight in the open model, so the number of requests is not known by --loop
+		if ratePerSecond > 0 && duration == 0 && requests == 0 {
+			panic("--rate requires either --duration or --requests")
 		}
-		if len(assertRegexExpression) > 0 {
-			assertions = append(assertions, &task.RegexAssertion{
-				Expression: assertRegexExpression,
-			})
+		if histogramPrecision < 1 || histogramPrecision > 5 {
+			panic("--histogram-precision must be from 1 to 5")
+		}
+		if latencyMode != task.LatencyBody && latencyMode != task.LatencyHeaders {
+			panic("--latency-mode must be either 'body' or 'headers'")
+		}
+		if warmup > 0 && warmupRequests > 0 {
+			panic("--warmup and --warmup-requests can not be used together")
+		}
+		if adaptive.Enabled() {
+			if err := adaptive.Validate(); err != nil {
+				panic(err)
+			}
+			if ratePerSecond > 0 || len(stageList) > 0 {
+				panic("--target-latency can not be used together with --rate or --stages")
+			}
+			if duration == 0 {
+				panic("--duration is required by --target-latency")
+			}
 		}
+		assertions := buildAssertions()
 
 		taskDef := task.TaskDef{
-			Loop:        loop,
-			Concurrency: concurrency,
-			Timeout:     timeout,
+			Loop:               loop,
+			Concurrency:        concurrency,
+			Requests:           requests,
+			Duration:           duration,
+			Rate:               ratePerSecond,
+			Stages:             stageList,
+			Adaptive:           adaptive,
+			Data:               feeder,
+			Scenario:           scenario,
+			Mix:                mix,
+			Seed:               seed,
+			Warmup:             warmup,
+			WarmupRequests:     warmupRequests,
+			PrintWarmup:        printWarmup,
+			ThinkTime:          think,
+			Pacing:             pacing,
+			Interval:           interval,
+			PrintTimeline:      printTimeline,
+			Percentiles:        percentileList,
+			ExactLatency:       exactLatency,
+			HistogramPrecision: histogramPrecision,
+			LatencyMode:        latencyMode,
+			Timeout:            timeout,
 			// KeepAlive:   keepAlive,
 			URL:        url,
 			Method:     method,
@@ -104,11 +232,92 @@ httptester run --loop 10 --concurrency 100 --timeout 500ms --keep-alive false
 		}
 		// data, _ := json.MarshalIndent(plan, "", "  ")
 		// fmt.Printf("%s\n", data)
-		plan.Start()
-
+		plan.StartContext(interruptibleContext())
+		if code := exitCodeOf(plan); code != 0 {
+			os.Exit(code)
+		}
 	},
 }
 
+const (
+	// exitCodeInterrupted is used when the run is interrupted and the report is partial
+	exitCodeInterrupted = 130
+	// exitCodeForced is used when the run is interrupted twice and exits immediately
+	exitCodeForced = 137
+)
+
+// interruptibleContext returns a context which is done on the first SIGINT or SIGTERM, the second one exits immediately
+func interruptibleContext() context.Context {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	return cancelOnSignals(signals, os.Exit)
+}
+
+// cancelOnSignals returns a context which is done on the first signal, the second one calls exit with exitCodeForced
+func cancelOnSignals(signals <-chan os.Signal, exit func(int)) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-signals
+		fmt.Fprintln(os.Stderr, "\ninterrupted, waiting for the in-flight requests, press Ctrl-C again to exit immediately")
+		cancel()
+		<-signals
+		exit(exitCodeForced)
+	}()
+	return ctx
+}
+
+// exitCodeOf returns exitCodeInterrupted if the report of the plan is partial, or 0 otherwise
+func exitCodeOf(plan *task.Plan) int {
+	if plan.Interrupted() {
+		return exitCodeInterrupted
+	}
+	return 0
+}
+
+// buildAssertions creates the assertions from the assertion flags
+func buildAssertions() []task.Assertion {
+	assertions := make([]task.Assertion, 0, 8)
+	if len(assertStatusCodes) > 0 {
+		intAssertStatusCodes := make([]int, 0, 8)
+		for _, code := range strings.Split(assertStatusCodes, " ") {
+			codeInt, err := strconv.Atoi(code)
+			if err != nil {
+				panic(err)
+			}
+			intAssertStatusCodes = append(intAssertStatusCodes, codeInt)
+		}
+		assertions = append(assertions, &task.StatusCodeAssertion{
+			ExpectedCodes: intAssertStatusCodes,
+		})
+	}
+	if len(assertJSONExpression) > 0 {
+		assertions = append(assertions, &task.JsonPathAssertion{
+			Expression: assertJSONExpression,
+		})
+	}
+	if len(assertRegexExpression) > 0 {
+		assertions = append(assertions, &task.RegexAssertion{
+			Expression: assertRegexExpression,
+		})
+	}
+	return assertions
+}
+
+// loadStages parses the --stages flag, or reads the 'stages' from the config file if the flag is absent
+func loadStages() ([]task.Stage, error) {
+	if stages != "" {
+		return task.ParseStages(stages)
+	}
+	if !viper.IsSet("stages") {
+		return nil, nil
+	}
+	var stageList []task.Stage
+	if err := viper.UnmarshalKey("stages", &stageList); err != nil {
+		return nil, err
+	}
+	return stageList, task.ValidateStages(stageList)
+}
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 
@@ -125,15 +334,75 @@ func init() {
 	runCmd.Flags().BoolVarP(&disableBar, "disable-bar", "", false, "disable the progress bar")
 	runCmd.Flags().IntVarP(&loop, "loop", "l", 1, "how many requests would a goroutine send synchronously")
 	runCmd.Flags().IntVarP(&concurrency, "concurrency", "c", 1, "how many goroutines would run concurrently")
-	runCmd.Flags().DurationVarP(&timeout, "timeout", "t", 10*time.Second, "how many goroutines would run concurrently")
-	runCmd.Flags().StringVarP(&url, "url", "u", "", "the target url you want to test")
-	runCmd.Flags().StringVarP(&body, "body", "b", "", "the request body")
-	runCmd.Flags().StringArrayVarP(&headers, "header", "H", []string{}, "the headers")
-	runCmd.Flags().StringVarP(&assertStatusCodes, "assert-status-codes", "", "", "assertion: expected http response status codes, use space-splited string")
-	runCmd.Flags().StringVarP(&assertJSONExpression, "assert-json-expression", "", "", "assertion: use jsonpath expression to verify a field, e.g. '$.expensive == 10', which '$' means the root of the json body. see https://github.com/oliveagle/jsonpath for more details")
-	runCmd.Flags().StringVarP(&assertRegexExpression, "assert-regex-expression", "", "", "assertion: use regex expression to validate the response body, e.g. '$.expensive == 10'")
-	runCmd.Flags().StringVarP(&timeunit, "time-unit", "", "ms", "time unit for printing report and calculating the standard deviation. 'ms' for milli-second, 'mms' for micro-second, 'ns' for nano-second, 's' for second")
-	runCmd.Flags().StringVarP(&method, "method", "", "GET", "http method")
-	runCmd.Flags().BoolVarP(&printError, "print-error", "e", false, "to print the error information")
-	runCmd.Flags().BoolVarP(&insecure, "insecure", "", true, "to ignore ssl certificates")
+	runCmd.Flags().IntVarP(&requests, "requests", "", 0, "the exact total number of requests shared by all the goroutines, a free goroutine takes the next one. the loop is ignored if it is set")
+	runCmd.Flags().StringVarP(&rate, "rate", "", "", "send requests at a constant arrival rate regardless of the response times, e.g. '500/s', '30/m'. the concurrency becomes the maximum of in-flight requests, requests are reported as late or dropped if it is exhausted")
+	runCmd.Flags().StringVarP(&stages, "stages", "", "", "ramp the number of goroutines linearly from the concurrency through the stages, e.g. '2m:500,10m:500,2m:0' which is 'duration:target' for each stage. it can also be defined as 'stages' in the config file")
+	runCmd.Flags().DurationVarP(&adaptive.TargetLatency, "target-latency", "", 0, "adjust the number of goroutines during the run to keep the latency near the target, starting from the concurrency. --duration is required")
+	runCmd.Flags().Float64VarP(&adaptive.Percentile, "target-percentile", "", 95, "the percentile of the latency to keep near the target")
+	runCmd.Flags().StringVarP(&adaptive.Controller, "controller", "", task.AIMD, "how to adjust the number of goroutines for the target latency, 'aimd' for additive increase and multiplicative decrease, 'pid' for a PID-style controller")
+	runCmd.Flags().IntVarP(&adaptive.MaxConcurrency, "max-concurrency", "", 1000, "the max number of goroutines for the target latency")
+	runCmd.Flags().DurationVarP(&adaptive.Window, "window", "", time.Second, "how often the latency is measured and the number of goroutines is adjusted for the target latency")
+	runCmd.Flags().DurationVarP(&warmup, "warmup", "", 0, "the requests within the beginning duration are sent as usual but excluded from the statistics, it is included in the --duration")
+	runCmd.Flags().IntVarP(&warmupRequests, "warmup-requests", "", 0, "the first n requests are sent as usual but excluded from the statistics")
+	runCmd.Flags().BoolVarP(&printWarmup, "print-warmup", "", false, "to print the statistics of the warm-up requests separately")
+	runCmd.Flags().StringVarP(&thinkTime, "think-time", "", "", "the pause of each goroutine between two requests, it is not counted in the latency. e.g. '500ms' or 'constant:500ms', 'uniform:100ms,500ms' for min and max, 'normal:300ms,50ms' for mean and standard deviation, 'exponential:200ms' for mean. it does not apply to --rate")
+	runCmd.Flags().DurationVarP(&pacing, "pacing", "", 0, "the minimum period between the starts of two requests of each goroutine, it does not apply to --rate")
+	runCmd.Flags().DurationVarP(&interval, "interval", "", time.Second, "the size of each interval of the timeline, which has the counts and the latency percentiles of the requests finished within the interval")
+	runCmd.Flags().StringVarP(&percentiles, "percentiles", "", "50,75,90,95,99,99.9", "the percentiles of the latency to report, for all the requests and for each outcome")
+	runCmd.Flags().BoolVarP(&exactLatency, "exact-latency", "", false, "keep every latency to calculate the exact statistics, the memory grows with the number of requests")
+	runCmd.Flags().IntVarP(&histogramPrecision, "histogram-precision", "", task.DefaultHistogramPrecision, "the significant digits of the latency histogram, from 1 to 5, the percentiles are within 10^-precision of the exact ones")
+	runCmd.Flags().StringVarP(&scenarioFile, "file", "f", "", "the scenario file in yaml or json, each iteration runs its steps in order instead of the request of --url")
+	runCmd.Flags().StringVarP(&harFile, "har", "", "", "the HAR file recorded by a browser or a proxy, each iteration replays its requests in order with the pauses between them, like the scenario converted by 'httptester convert har'")
+	addHarFlags(runCmd, "har-")
+	runCmd.Flags().StringVarP(&mixFile, "mix", "", "", "the mix file in yaml or json, each iteration sends one of its requests picked at random by their weights instead of the request of --url")
+	runCmd.Flags().Int64VarP(&seed, "seed", "", 0, "the seed of the random choices, e.g. the requests of --mix, to make them repeatable, a random seed is used if it is 0")
+	runCmd.Flags().StringVarP(&dataFile, "data", "", "", "a csv file with a header line, or a json-lines file, each record provides its columns as variables like '${id}' to the url, headers and body")
+	runCmd.Flags().StringVarP(&dataMode, "data-mode", "", task.FeedSequential, "how the records are taken: 'sequential' in order by all the goroutines, 'random' for each request, or 'unique' to give each goroutine its own record")
+	runCmd.Flags().StringVarP(&dataExhausted, "data-exhausted", "", task.ExhaustedWrap, "'wrap' to start over once all the records are taken, or 'stop' to stop the run")
+	runCmd.Flags().StringVarP(&latencyMode, "latency-mode", "", task.LatencyBody, "'body' to measure the latency until the last byte of the response body, 'headers' until the response headers arrive")
+	runCmd.Flags().BoolVarP(&printTimeline, "timeline", "", false, "to print the timeline at the end")
+	runCmd.Flags().DurationVarP(&duration, "duration", "d", 0, "keep sending requests until the duration elapses, e.g. '10m', the loop is ignored if it is set")
+	addRequestFlags(runCmd)
+}
+
+// addRequestFlags adds the flags which define the request and its assertions, they are shared by the commands sending requests
+func addRequestFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVarP(&timeout, "timeout", "t", 10*time.Second, "how many goroutines would run concurrently")
+	cmd.Flags().StringVarP(&url, "url", "u", "", "the target url you want to test")
+	cmd.Flags().StringVarP(&body, "body", "b", "", "the request body")
+	cmd.Flags().StringArrayVarP(&headers, "header", "H", []string{}, "the headers")
+	cmd.Flags().StringVarP(&assertStatusCodes, "assert-status-codes", "", "", "assertion: expected http response status codes, use space-splited string")
+	cmd.Flags().StringVarP(&assertJSONExpression, "assert-json-expression", "", "", "assertion: use jsonpath expression to verify a field, e.g. '$.expensive == 10', which '$' means the root of the json body, or '$.id exists' to verify that the path exists. see https://github.com/oliveagle/jsonpath for more details")
+	cmd.Flags().StringVarP(&assertRegexExpression, "assert-regex-expression", "", "", "assertion: use regex expression to validate the response body, e.g. '$.expensive == 10'")
+	cmd.Flags().StringVarP(&timeunit, "time-unit", "", "ms", "time unit for printing the report, the latencies are always measured in nano-seconds. 'auto' picks the unit by the median, 'ms' for milli-second, 'mms' for micro-second, 'ns' for nano-second, 's' for second")
+	cmd.Flags().StringVarP(&method, "method", "", "GET", "http method")
+	cmd.Flags().BoolVarP(&printError, "print-error", "e", false, "to print the error information")
+	cmd.Flags().BoolVarP(&insecure, "insecure", "", true, "to ignore ssl certificates")
+	cmd.Flags().StringVarP(&fromCurl, "from-curl", "", "", "a curl command line which defines the request instead of --url, e.g. one copied from the devtools of a browser. --method, --body and --timeout override it, --header adds to its headers")
+}
+
+// applyCurl defines the request by --from-curl, the flags given explicitly take precedence over it
+func applyCurl(cmd *cobra.Command) {
+	if fromCurl == "" {
+		return
+	}
+	if url != "" {
+		panic("--url and --from-curl can not be used together")
+	}
+	r, err := convert.ParseCurl(fromCurl)
+	if err != nil {
+		panic(err)
+	}
+	url = r.Step.URL
+	if !cmd.Flags().Changed("method") {
+		method = r.Step.Method
+	}
+	if !cmd.Flags().Changed("body") {
+		body = r.Step.Body
+	}
+	if !cmd.Flags().Changed("timeout") && r.Step.Timeout > 0 {
+		timeout = r.Step.Timeout
+	}
+	headers = append(r.Step.Headers, headers...)
+	insecure = insecure || r.Insecure
 }
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rocketk/httptester/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelOnSignals(t *testing.T) {
+	ast := assert.New(t)
+	signals := make(chan os.Signal, 2)
+	exited := make(chan int, 1)
+	ctx := cancelOnSignals(signals, func(code int) { exited <- code })
+	ast.Nil(ctx.Err())
+
+	signals <- syscall.SIGINT
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the context is not done on the first signal")
+	}
+	ast.Empty(exited)
+
+	signals <- syscall.SIGTERM
+	select {
+	case code := <-exited:
+		ast.Equal(exitCodeForced, code)
+	case <-time.After(time.Second):
+		t.Fatal("the second signal does not exit")
+	}
+}
+
+func TestExitCodeOfInterruptedPlan(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+	newPlan := func() *task.Plan {
+		return &task.Plan{TaskDef: task.TaskDef{Loop: 1000, Concurrency: 2, URL: server.URL, Method: http.MethodGet,
+			DisableBar: true, DisableReport: true}}
+	}
+	signals := make(chan os.Signal, 1)
+	plan := newPlan()
+	ctx := cancelOnSignals(signals, func(int) {})
+	time.AfterFunc(50*time.Millisecond, func() { signals <- syscall.SIGINT })
+	plan.StartContext(ctx)
+	ast.Equal(exitCodeInterrupted, exitCodeOf(plan))
+	ast.Less(plan.Result().TotalCount(), 2000)
+
+	plan = newPlan()
+	plan.TaskDef.Loop = 1
+	plan.Start()
+	ast.Zero(exitCodeOf(plan))
+}
@@ -0,0 +1,48 @@
+// Package convert turns the requests recorded or shared by other tools, e.g. curl command lines, into scenarios of httptester
+package convert
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rocketk/httptester/task"
+	"gopkg.in/yaml.v3"
+)
+
+// ScenarioOf makes a scenario of the requests in order
+func ScenarioOf(name string, requests []*CurlRequest) *task.Scenario {
+	s := &task.Scenario{Name: name, Steps: make([]task.Step, 0, len(requests))}
+	for _, r := range requests {
+		s.Steps = append(s.Steps, r.Step)
+	}
+	return s
+}
+
+// WriteScenario writes the scenario as YAML, which can be run by 'httptester run -f'
+func WriteScenario(w io.Writer, s *task.Scenario) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(s); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// stepNames counts the names of the steps, to number the repeated ones since the names of the steps of a scenario must be unique
+type stepNames map[string]int
+
+// unique returns the name, or the name with its number like 'Get user (2)' if it has been used
+func (n stepNames) unique(name string) string {
+	n[name]++
+	if c := n[name]; c > 1 {
+		return fmt.Sprintf("%s (%d)", name, c)
+	}
+	return name
+}
+
+// escapeTemplate escapes the '{{' of an imported text, e.g. a Mustache template in a body,
+// which would be taken as a template of httptester otherwise
+func escapeTemplate(s string) string {
+	return strings.ReplaceAll(s, "{{", `{{"{{"}}`)
+}
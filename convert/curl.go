@@ -0,0 +1,423 @@
+package convert
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rocketk/httptester/task"
+)
+
+// CurlRequest is a request parsed from a curl command line
+type CurlRequest struct {
+	Step task.Step
+	// Insecure is true if the command has -k or --insecure
+	Insecure bool
+}
+
+// curlOptionsWithValue are the options of curl which take a value but do not affect the request, they are skipped with the value
+var curlOptionsWithValue = map[string]bool{
+	"-o": true, "--output": true, "-w": true, "--write-out": true, "--connect-timeout": true, "--retry": true,
+	"-x": true, "--proxy": true, "-c": true, "--cookie-jar": true, "--max-redirs": true, "--cacert": true,
+	"-E": true, "--cert": true, "--key": true, "--resolve": true, "--connect-to": true, "-D": true, "--dump-header": true,
+}
+
+// curlRequestOptions are the options of curl which take a value and make the request
+var curlRequestOptions = map[string]bool{
+	"-X": true, "--request": true, "-H": true, "--header": true, "-d": true, "--data": true, "--data-raw": true,
+	"--data-binary": true, "--data-ascii": true, "--data-urlencode": true, "--json": true, "-u": true, "--user": true,
+	"-b": true, "--cookie": true, "-A": true, "--user-agent": true, "-e": true, "--referer": true, "-m": true, "--max-time": true,
+	"--url": true,
+}
+
+// curlFlags are the options of curl without a value which do not affect the request, --compressed is one of them
+// since the http client of Go asks for gzip and decompresses the body by itself
+var curlFlags = map[string]bool{
+	"--compressed": true, "-s": true, "--silent": true, "-S": true, "--show-error": true, "-v": true, "--verbose": true,
+	"-i": true, "--include": true, "-L": true, "--location": true, "-f": true, "--fail": true, "-N": true, "--no-buffer": true,
+	"--http1.1": true, "--http2": true, "-#": true, "--progress-bar": true, "-g": true, "--globoff": true,
+}
+
+// ParseCurl parses a curl command line, e.g. one copied from the devtools of a browser
+func ParseCurl(command string) (*CurlRequest, error) {
+	commands, err := ParseCurls(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) != 1 {
+		return nil, fmt.Errorf("expected 1 curl command, but got %d", len(commands))
+	}
+	return commands[0], nil
+}
+
+// ParseCurls parses the curl commands separated by new lines, ';' or '&&', e.g. those copied by 'Copy all as cURL'
+func ParseCurls(text string) ([]*CurlRequest, error) {
+	commands, err := splitShellWords(text)
+	if err != nil {
+		return nil, err
+	}
+	requests := make([]*CurlRequest, 0, len(commands))
+	for _, args := range commands {
+		r, err := parseCurlArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, r)
+	}
+	if len(requests) == 0 {
+		return nil, errors.New("no curl command is found")
+	}
+	return requests, nil
+}
+
+// parseCurlArgs parses the words of a curl command, the first of which is 'curl'
+func parseCurlArgs(args []string) (*CurlRequest, error) {
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, fmt.Errorf("not a curl command: %s", strings.Join(args, " "))
+	}
+	args = expandShortFlags(args)
+	r := &CurlRequest{}
+	var method, rawURL, user string
+	var data []string
+	var get, hasContentType bool
+	headers := make([]string, 0, 8)
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		name, value, inline := splitCurlOption(arg)
+		needsValue := func() (string, error) {
+			if inline {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("the curl option %s requires a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if rawURL != "" {
+				return nil, fmt.Errorf("more than 1 url in the curl command: %s, %s", rawURL, arg)
+			}
+			rawURL = arg
+			continue
+		}
+		var v string
+		var err error
+		switch name {
+		case "-X", "--request":
+			if v, err = needsValue(); err == nil {
+				method = strings.ToUpper(v)
+			}
+		case "-H", "--header":
+			if v, err = needsValue(); err == nil {
+				if strings.EqualFold(headerName(v), "Content-Type") {
+					hasContentType = true
+				}
+				// like --compressed, it is left to the client, which only decompresses the body if it asks for gzip by itself
+				if !strings.EqualFold(headerName(v), "Accept-Encoding") {
+					headers = append(headers, v)
+				}
+			}
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii":
+			if v, err = needsValue(); err == nil {
+				if strings.HasPrefix(v, "@") && name != "--data-raw" {
+					return nil, fmt.Errorf("reading the data from a file is not supported: %s %s", name, v)
+				}
+				data = append(data, v)
+			}
+		case "--data-urlencode":
+			if v, err = needsValue(); err == nil {
+				data = append(data, urlencodeData(v))
+			}
+		case "--json":
+			if v, err = needsValue(); err == nil {
+				data = append(data, v)
+				headers = append(headers, "Content-Type: application/json", "Accept: application/json")
+				hasContentType = true
+			}
+		case "-u", "--user":
+			user, err = needsValue()
+		case "-b", "--cookie":
+			if v, err = needsValue(); err == nil {
+				if !strings.Contains(v, "=") {
+					return nil, fmt.Errorf("reading the cookies from a file is not supported: %s %s", name, v)
+				}
+				headers = append(headers, "Cookie: "+v)
+			}
+		case "-A", "--user-agent":
+			if v, err = needsValue(); err == nil {
+				headers = append(headers, "User-Agent: "+v)
+			}
+		case "-e", "--referer":
+			if v, err = needsValue(); err == nil {
+				headers = append(headers, "Referer: "+v)
+			}
+		case "-m", "--max-time":
+			if v, err = needsValue(); err == nil {
+				var seconds float64
+				if seconds, err = strconv.ParseFloat(v, 64); err == nil {
+					r.Step.Timeout = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		case "--url":
+			rawURL, err = needsValue()
+		case "-k", "--insecure":
+			r.Insecure = true
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			method = http.MethodHead
+		default:
+			if curlOptionsWithValue[name] {
+				_, err = needsValue()
+			} else if !curlFlags[name] {
+				err = fmt.Errorf("unsupported curl option %s", name)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rawURL == "" {
+		return nil, errors.New("the url is missing in the curl command")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	if user != "" {
+		headers = append(headers, "Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte(user)))
+	}
+	body := strings.Join(data, "&")
+	if get && body != "" {
+		separator := "?"
+		if strings.Contains(rawURL, "?") {
+			separator = "&"
+		}
+		rawURL += separator + body
+		body = ""
+	}
+	if method == "" {
+		method = http.MethodGet
+		if body != "" {
+			method = http.MethodPost
+		}
+	}
+	// curl posts the data as a form unless the content type is given
+	if body != "" && !hasContentType {
+		headers = append(headers, "Content-Type: application/x-www-form-urlencoded")
+	}
+	r.Step.Method = method
+	r.Step.URL = escapeTemplate(rawURL)
+	r.Step.Body = escapeTemplate(body)
+	for i := range headers {
+		headers[i] = escapeTemplate(headers[i])
+	}
+	if len(headers) > 0 {
+		r.Step.Headers = headers
+	}
+	return r, nil
+}
+
+// curlShortFlags are the short options without a value, which may be put together like '-sSLk'
+const curlShortFlags = "ksSvifLNGIg#"
+
+// expandShortFlags splits the short options put together, the last of which may take the rest of the word as its value, e.g. '-sXPOST'.
+// the value of an option in the next word is kept as it is, even if it looks like options, e.g. '-d -sfoo'
+func expandShortFlags(args []string) []string {
+	result := make([]string, 0, len(args))
+	isValue := false
+	for _, arg := range args {
+		if isValue {
+			result = append(result, arg)
+			isValue = false
+			continue
+		}
+		for len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && strings.IndexByte(curlShortFlags, arg[1]) >= 0 {
+			result = append(result, arg[:2])
+			arg = "-" + arg[2:]
+		}
+		result = append(result, arg)
+		if strings.HasPrefix(arg, "-") {
+			name, _, inline := splitCurlOption(arg)
+			isValue = !inline && (curlRequestOptions[name] || curlOptionsWithValue[name])
+		}
+	}
+	return result
+}
+
+// splitCurlOption splits '--data=x' into the name and the value, inline is true if the value is in the same word
+func splitCurlOption(arg string) (name string, value string, inline bool) {
+	if strings.HasPrefix(arg, "--") {
+		if i := strings.Index(arg, "="); i > 0 {
+			return arg[:i], arg[i+1:], true
+		}
+		return arg, "", false
+	}
+	// a short option may be followed by its value in the same word, e.g. '-XPOST'
+	if len(arg) > 2 && arg[0] == '-' {
+		return arg[:2], arg[2:], true
+	}
+	return arg, "", false
+}
+
+// headerName returns the name of a header like 'Content-Type: application/json'
+func headerName(header string) string {
+	return strings.TrimSpace(strings.SplitN(header, ":", 2)[0])
+}
+
+// urlencodeData encodes the data of --data-urlencode like curl, which is 'content', 'name=content' or '=content'
+func urlencodeData(v string) string {
+	if i := strings.Index(v, "="); i >= 0 {
+		if i == 0 {
+			return url.QueryEscape(v[1:])
+		}
+		return v[:i] + "=" + url.QueryEscape(v[i+1:])
+	}
+	return url.QueryEscape(v)
+}
+
+// splitShellWords splits the text into commands and their words like a POSIX shell, with single, double and ANSI-C ($'...') quotes,
+// and a backslash before a new line to continue the command. the commands are separated by new lines, ';' and '&&'
+func splitShellWords(text string) ([][]string, error) {
+	commands := make([][]string, 0, 1)
+	words := make([]string, 0, 16)
+	var word strings.Builder
+	inWord := false
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+			words = make([]string, 0, 16)
+		}
+	}
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(runes) {
+				i++
+				// a backslash before a new line continues the command, the windows style '^' is not supported
+				if runes[i] == '\n' || (runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n') {
+					if runes[i] == '\r' {
+						i++
+					}
+					continue
+				}
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := indexRune(runes, '\'', i+1)
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			i = end
+		case c == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			end, err := readANSIQuote(runes, i+2, &word)
+			if err != nil {
+				return nil, err
+			}
+			inWord = true
+			i = end
+		case c == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				// only these characters are escaped by a backslash in double quotes
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		case c == '\n' || c == ';':
+			endCommand()
+		case c == '&' && i+1 < len(runes) && runes[i+1] == '&':
+			i++
+			endCommand()
+		case c == ' ' || c == '\t' || c == '\r':
+			endWord()
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	endCommand()
+	return commands, nil
+}
+
+func indexRune(runes []rune, r rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// readANSIQuote reads the content of $'...' from the start into the word, and returns the index of the closing quote
+func readANSIQuote(runes []rune, start int, word *strings.Builder) (int, error) {
+	for i := start; i < len(runes); i++ {
+		c := runes[i]
+		if c == '\'' {
+			return i, nil
+		}
+		if c != '\\' || i+1 >= len(runes) {
+			word.WriteRune(c)
+			continue
+		}
+		i++
+		switch runes[i] {
+		case 'n':
+			word.WriteRune('\n')
+		case 't':
+			word.WriteRune('\t')
+		case 'r':
+			word.WriteRune('\r')
+		case 'x', 'u':
+			// \xHH and \uHHHH
+			size := 2
+			if runes[i] == 'u' {
+				size = 4
+			}
+			end := i + 1
+			for end < len(runes) && end < i+1+size && strings.ContainsRune("0123456789abcdefABCDEF", runes[end]) {
+				end++
+			}
+			code, err := strconv.ParseUint(string(runes[i+1:end]), 16, 32)
+			if err != nil {
+				return 0, fmt.Errorf("invalid escape in $'...': \\%s", string(runes[i:end]))
+			}
+			if size == 2 {
+				word.WriteByte(byte(code))
+			} else {
+				word.WriteRune(rune(code))
+			}
+			i = end - 1
+		default:
+			// \\, \' and \" are the characters themselves
+			word.WriteRune(runes[i])
+		}
+	}
+	return 0, errors.New("unterminated $' quote")
+}
@@ -0,0 +1,124 @@
+package convert
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rocketk/httptester/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCurl(t *testing.T) {
+	ast := assert.New(t)
+	r, err := ParseCurl(`curl -X POST 'http://localhost:1234/users' \
+  -H 'Content-Type: application/json' \
+  -H 'Accept-Encoding: gzip, deflate, br' \
+  -H "Referer: http://localhost:1234/?at=12:30" \
+  --data-raw '{"name":"tester","age":20}' \
+  -b 'SESSIONID=s1; theme=dark' \
+  -u admin:secret \
+  --compressed --insecure -m 2.5`)
+	ast.Nil(err)
+	ast.Equal(http.MethodPost, r.Step.Method)
+	ast.Equal("http://localhost:1234/users", r.Step.URL)
+	ast.Equal(`{"name":"tester","age":20}`, r.Step.Body)
+	ast.Equal([]string{
+		"Content-Type: application/json",
+		"Referer: http://localhost:1234/?at=12:30",
+		"Cookie: SESSIONID=s1; theme=dark",
+		"Authorization: Basic YWRtaW46c2VjcmV0",
+	}, r.Step.Headers)
+	ast.True(r.Insecure)
+	ast.Equal(2500*time.Millisecond, r.Step.Timeout)
+
+	cases := []struct {
+		command string
+		method  string
+		url     string
+		body    string
+		headers []string
+	}{
+		{"curl localhost:1234/users", "GET", "http://localhost:1234/users", "", nil},
+		{"curl -d name=tester -d age=20 http://localhost/users", "POST", "http://localhost/users", "name=tester&age=20",
+			[]string{"Content-Type: application/x-www-form-urlencoded"}},
+		{"curl -G --data-urlencode 'q=a b' 'http://localhost/users?page=1'", "GET", "http://localhost/users?page=1&q=a+b", "", nil},
+		{"curl -sSLXPUT --url http://localhost/users/1 --data='{}' -H 'Content-Type: application/json'", "PUT", "http://localhost/users/1", "{}",
+			[]string{"Content-Type: application/json"}},
+		{`curl 'http://localhost/users' --data-raw $'{"name":"it\'s\né\x21"}' -H 'content-type: application/json'`, "POST", "http://localhost/users",
+			"{\"name\":\"it's\né!\"}", []string{"content-type: application/json"}},
+		{"curl -I -A tester -e http://localhost/ http://localhost/users", "HEAD", "http://localhost/users", "",
+			[]string{"User-Agent: tester", "Referer: http://localhost/"}},
+		{"curl --compressed -H 'accept-encoding: gzip' http://localhost/users", "GET", "http://localhost/users", "", nil},
+		// the values are not taken for the short options put together
+		{"curl -sd -sfoo -H -kX -o -Lv http://localhost/users", "POST", "http://localhost/users", "-sfoo",
+			[]string{"-kX", "Content-Type: application/x-www-form-urlencoded"}},
+	}
+	for _, c := range cases {
+		r, err := ParseCurl(c.command)
+		ast.Nil(err, c.command)
+		if err != nil {
+			continue
+		}
+		ast.Equal(c.method, r.Step.Method, c.command)
+		ast.Equal(c.url, r.Step.URL, c.command)
+		ast.Equal(c.body, r.Step.Body, c.command)
+		ast.Equal(c.headers, r.Step.Headers, c.command)
+	}
+
+	for _, command := range []string{
+		"wget http://localhost/",
+		"curl -X",
+		"curl -H 'X-A: 1'",
+		"curl --upload-file a.txt http://localhost/",
+		"curl -d @body.json http://localhost/",
+		"curl -b cookies.txt http://localhost/",
+		"curl 'http://localhost/",
+		"curl http://localhost/a http://localhost/b",
+		"curl http://localhost/a\ncurl http://localhost/b",
+	} {
+		_, err := ParseCurl(command)
+		ast.NotNil(err, command)
+	}
+}
+
+func TestConvertCurls(t *testing.T) {
+	ast := assert.New(t)
+	requests, err := ParseCurls(`curl 'http://localhost:1234/users' -H 'Accept: application/json' ;
+curl 'http://localhost:1234/users' -H 'Content-Type: application/json' --data-raw '{"name":"tester"}' &&
+curl -X DELETE http://localhost:1234/users/1
+`)
+	ast.Nil(err)
+	ast.Len(requests, 3)
+	var b bytes.Buffer
+	ast.Nil(WriteScenario(&b, ScenarioOf("users", requests)))
+	ast.Equal(`name: users
+steps:
+  - method: GET
+    url: http://localhost:1234/users
+    headers:
+      - 'Accept: application/json'
+  - method: POST
+    url: http://localhost:1234/users
+    headers:
+      - 'Content-Type: application/json'
+    body: '{"name":"tester"}'
+  - method: DELETE
+    url: http://localhost:1234/users/1
+`, b.String())
+	s, err := task.ParseScenario(b.Bytes())
+	ast.Nil(err)
+	ast.Equal([]string{"1 GET http://localhost:1234/users", "2 POST http://localhost:1234/users", "3 DELETE http://localhost:1234/users/1"}, s.StepNames())
+}
+
+func TestParseCurlWithBraces(t *testing.T) {
+	ast := assert.New(t)
+	r, err := ParseCurl(`curl 'http://localhost/{{id}}' -H 'X-Tag: {{tag}}' -d '{"t":"{{name}}"}'`)
+	ast.Nil(err)
+	// the '{{' of the command is sent as it is rather than taken as a template
+	ast.Equal(`http://localhost/{{"{{"}}id}}`, r.Step.URL)
+	ast.Equal(`{"t":"{{"{{"}}name}}"}`, r.Step.Body)
+	ast.Equal(`X-Tag: {{"{{"}}tag}}`, r.Step.Headers[0])
+	ast.Nil(task.ValidateTemplate(r.Step.Body))
+}
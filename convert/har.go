@@ -0,0 +1,184 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rocketk/httptester/task"
+)
+
+// DefaultStaticContentTypes are the content types of the static assets, which are dropped from a HAR file by default
+var DefaultStaticContentTypes = []string{"image/", "font/", "text/css", "javascript", "video/", "audio/"}
+
+// HarOptions are the filters of the entries of a HAR file, an entry is kept if it matches all of them
+type HarOptions struct {
+	// Hosts keeps the requests to these hosts or their subdomains, all of the hosts are kept if it is empty
+	Hosts []string
+	// Methods keeps the requests of these methods, all of the methods are kept if it is empty
+	Methods []string
+	// ContentTypes keeps the responses whose content type contains one of these, e.g. 'json'
+	ContentTypes []string
+	// ExcludeContentTypes drops the responses whose content type contains one of these, e.g. 'image/'
+	ExcludeContentTypes []string
+	// IgnoreDelays drops the pauses between the requests, otherwise they become the delays of the steps
+	IgnoreDelays bool
+}
+
+type har struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	// Time is the total time of the request in milliseconds
+	Time    float64 `json:"time"`
+	Request struct {
+		Method   string    `json:"method"`
+		URL      string    `json:"url"`
+		Headers  []harPair `json:"headers"`
+		PostData *struct {
+			MimeType string    `json:"mimeType"`
+			Text     string    `json:"text"`
+			Params   []harPair `json:"params"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int `json:"status"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harSkippedHeaders are set by the http client by itself, Accept-Encoding is one of them since the client only decompresses
+// the body if it asks for gzip by itself
+var harSkippedHeaders = map[string]bool{
+	"host": true, "content-length": true, "connection": true, "accept-encoding": true, "keep-alive": true,
+	"transfer-encoding": true, "upgrade": true, "proxy-connection": true,
+}
+
+// LoadHAR reads a HAR file recorded by a browser or a proxy
+func LoadHAR(path string, options HarOptions) (*task.Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseHAR(data, options)
+}
+
+// ParseHAR turns the entries of a HAR file which match the options into the steps of a scenario in the order they started,
+// the pause between the end of a request and the start of the next one becomes the delay of the next step,
+// and the status of the recorded response is asserted
+func ParseHAR(data []byte, options HarOptions) (*task.Scenario, error) {
+	h := &har{}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+	entries := h.Log.Entries
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedDateTime.Before(entries[j].StartedDateTime)
+	})
+	s := &task.Scenario{Steps: make([]task.Step, 0, len(entries))}
+	var lastEnd time.Time
+	for _, e := range entries {
+		if !options.match(e) {
+			continue
+		}
+		step := task.Step{
+			Method: strings.ToUpper(e.Request.Method),
+			URL:    escapeTemplate(e.Request.URL),
+		}
+		for _, header := range e.Request.Headers {
+			// the pseudo headers of HTTP/2 like ':authority' are skipped as well
+			if strings.HasPrefix(header.Name, ":") || harSkippedHeaders[strings.ToLower(header.Name)] {
+				continue
+			}
+			step.Headers = append(step.Headers, escapeTemplate(header.Name+": "+header.Value))
+		}
+		if p := e.Request.PostData; p != nil {
+			step.Body = escapeTemplate(p.Text)
+			if step.Body == "" && len(p.Params) > 0 {
+				form := url.Values{}
+				for _, param := range p.Params {
+					form.Add(param.Name, param.Value)
+				}
+				step.Body = form.Encode()
+			}
+		}
+		if e.Response.Status > 0 {
+			step.Assert.StatusCodes = []int{e.Response.Status}
+		}
+		if !options.IgnoreDelays && !lastEnd.IsZero() && e.StartedDateTime.After(lastEnd) {
+			step.Delay = e.StartedDateTime.Sub(lastEnd).Round(time.Millisecond)
+		}
+		if end := e.StartedDateTime.Add(time.Duration(e.Time * float64(time.Millisecond))); end.After(lastEnd) {
+			lastEnd = end
+		}
+		s.Steps = append(s.Steps, step)
+	}
+	if len(s.Steps) == 0 {
+		return nil, errors.New("no entries of the HAR match the filters")
+	}
+	return s, nil
+}
+
+// match reports whether the entry is kept by the options
+func (o HarOptions) match(e harEntry) bool {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if len(o.Hosts) > 0 && !matchHost(u.Hostname(), o.Hosts) {
+		return false
+	}
+	if len(o.Methods) > 0 && !containsFold(o.Methods, e.Request.Method) {
+		return false
+	}
+	contentType := strings.ToLower(e.Response.Content.MimeType)
+	if len(o.ContentTypes) > 0 && !containsAny(contentType, o.ContentTypes) {
+		return false
+	}
+	return !containsAny(contentType, o.ExcludeContentTypes)
+}
+
+// matchHost reports whether the host is one of the hosts or their subdomains
+func matchHost(host string, hosts []string) bool {
+	for _, h := range hosts {
+		if strings.EqualFold(host, h) || strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(h)) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny reports whether s contains one of the non-empty patterns, which are case-insensitive
+func containsAny(s string, patterns []string) bool {
+	for _, p := range patterns {
+		if p != "" && strings.Contains(s, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package convert
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rocketk/httptester/task"
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleHAR = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "startedDateTime": "2023-05-01T10:00:00.000Z",
+        "time": 120,
+        "request": {
+          "method": "GET",
+          "url": "http://localhost:1234/users",
+          "headers": [
+            {"name": ":authority", "value": "localhost:1234"},
+            {"name": "Accept", "value": "application/json"},
+            {"name": "Accept-Encoding", "value": "gzip, deflate, br"},
+            {"name": "Cookie", "value": "SESSIONID=s1"}
+          ]
+        },
+        "response": {"status": 200, "content": {"mimeType": "application/json; charset=utf-8"}}
+      },
+      {
+        "startedDateTime": "2023-05-01T10:00:00.050Z",
+        "time": 30,
+        "request": {"method": "GET", "url": "http://localhost:1234/logo.png", "headers": []},
+        "response": {"status": 200, "content": {"mimeType": "image/png"}}
+      },
+      {
+        "startedDateTime": "2023-05-01T10:00:03.620Z",
+        "time": 80,
+        "request": {
+          "method": "POST",
+          "url": "http://localhost:1234/users",
+          "headers": [{"name": "Content-Type", "value": "application/json"}, {"name": "Content-Length", "value": "17"}],
+          "postData": {"mimeType": "application/json", "text": "{\"name\":\"tester\"}"}
+        },
+        "response": {"status": 201, "content": {"mimeType": "application/json"}}
+      },
+      {
+        "startedDateTime": "2023-05-01T10:00:02.000Z",
+        "time": 10,
+        "request": {
+          "method": "POST",
+          "url": "https://cdn.example.com/track",
+          "headers": [],
+          "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "event", "value": "view page"}]}
+        },
+        "response": {"status": 0, "content": {"mimeType": ""}}
+      },
+      {
+        "startedDateTime": "2023-05-01T10:00:04.000Z",
+        "time": 5,
+        "request": {"method": "GET", "url": "data:image/png;base64,AAAA", "headers": []},
+        "response": {"status": 200, "content": {"mimeType": "image/png"}}
+      }
+    ]
+  }
+}`
+
+func TestParseHAR(t *testing.T) {
+	ast := assert.New(t)
+	s, err := ParseHAR([]byte(sampleHAR), HarOptions{ExcludeContentTypes: DefaultStaticContentTypes})
+	ast.Nil(err)
+	ast.Len(s.Steps, 3)
+	ast.Equal(task.Step{
+		Method:  "GET",
+		URL:     "http://localhost:1234/users",
+		Headers: []string{"Accept: application/json", "Cookie: SESSIONID=s1"},
+		Assert:  task.StepAssertions{StatusCodes: []int{200}},
+	}, s.Steps[0])
+	// the entries are sorted by their start, and the delays are from the end of the previous one
+	ast.Equal("https://cdn.example.com/track", s.Steps[1].URL)
+	ast.Equal("event=view+page", s.Steps[1].Body)
+	ast.Nil(s.Steps[1].Assert.StatusCodes)
+	ast.Equal(1880*time.Millisecond, s.Steps[1].Delay)
+	ast.Equal([]string{"Content-Type: application/json"}, s.Steps[2].Headers)
+	ast.Equal(`{"name":"tester"}`, s.Steps[2].Body)
+	ast.Equal(1610*time.Millisecond, s.Steps[2].Delay)
+
+	cases := []struct {
+		options HarOptions
+		urls    []string
+	}{
+		{HarOptions{}, []string{"http://localhost:1234/users", "http://localhost:1234/logo.png", "https://cdn.example.com/track", "http://localhost:1234/users"}},
+		{HarOptions{Hosts: []string{"example.com"}}, []string{"https://cdn.example.com/track"}},
+		{HarOptions{Hosts: []string{"LOCALHOST"}, Methods: []string{"post"}}, []string{"http://localhost:1234/users"}},
+		{HarOptions{ContentTypes: []string{"JSON"}}, []string{"http://localhost:1234/users", "http://localhost:1234/users"}},
+	}
+	for _, c := range cases {
+		s, err := ParseHAR([]byte(sampleHAR), c.options)
+		ast.Nil(err)
+		urls := make([]string, 0, len(s.Steps))
+		for _, step := range s.Steps {
+			urls = append(urls, step.URL)
+		}
+		ast.Equal(c.urls, urls)
+	}
+
+	s, err = ParseHAR([]byte(sampleHAR), HarOptions{ContentTypes: []string{"json"}, IgnoreDelays: true})
+	ast.Nil(err)
+	ast.Zero(s.Steps[1].Delay)
+	_, err = ParseHAR([]byte(sampleHAR), HarOptions{Hosts: []string{"example.org"}})
+	ast.NotNil(err)
+	_, err = ParseHAR([]byte(`{"log": []}`), HarOptions{})
+	ast.NotNil(err)
+
+	// the written scenario can be read back
+	s, err = ParseHAR([]byte(sampleHAR), HarOptions{ExcludeContentTypes: DefaultStaticContentTypes})
+	ast.Nil(err)
+	var b bytes.Buffer
+	ast.Nil(WriteScenario(&b, s))
+	ast.Contains(b.String(), "delay: 1.61s")
+	loaded, err := task.ParseScenario(b.Bytes())
+	ast.Nil(err)
+	ast.Equal(1610*time.Millisecond, loaded.Steps[2].Delay)
+}
+
+func TestParseHARWithBraces(t *testing.T) {
+	ast := assert.New(t)
+	s, err := ParseHAR([]byte(`{"log": {"entries": [{"startedDateTime": "2023-05-01T10:00:00.000Z", "request": {"method": "POST",
+  "url": "http://localhost/{{id}}", "headers": [{"name": "X-Tag", "value": "{{tag}}"}],
+  "postData": {"mimeType": "text/plain", "text": "Hello {{name}}"}}, "response": {"status": 200, "content": {}}}]}}`), HarOptions{})
+	ast.Nil(err)
+	// the '{{' of the recorded requests is sent as it is rather than taken as a template
+	ast.Equal(`http://localhost/{{"{{"}}id}}`, s.Steps[0].URL)
+	ast.Equal([]string{`X-Tag: {{"{{"}}tag}}`}, s.Steps[0].Headers)
+	ast.Equal(`Hello {{"{{"}}name}}`, s.Steps[0].Body)
+	ast.Nil(s.Validate())
+}
@@ -0,0 +1,668 @@
+package convert
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rocketk/httptester/task"
+)
+
+// JMXOptions are the options of converting a JMeter test plan
+type JMXOptions struct {
+	// ThreadGroup is the name of the thread group to convert, the first enabled one is converted if it is empty
+	ThreadGroup string
+}
+
+// JMXPlan is a JMeter test plan converted into a scenario, with the load of its thread group
+type JMXPlan struct {
+	Scenario *task.Scenario
+	// Concurrency is the number of the threads
+	Concurrency int
+	// Loop is the number of the loops of each thread, 0 if it is limited by the Duration
+	Loop   int
+	RampUp time.Duration
+	// Duration is the duration of the scheduler of the thread group, 0 if it is not set
+	Duration time.Duration
+	// DataFile is the file of the CSV data set, whose columns are the variables of the threads
+	DataFile string
+	// StopOnData stops the run once the records of the data file are used up, instead of starting over
+	StopOnData bool
+	// Warnings lists the elements of the test plan which are not converted, or converted partially
+	Warnings []string
+}
+
+// jmxForever is the duration assumed for a thread group which loops forever
+const jmxForever = 10 * time.Minute
+
+// the bits of the test type of a ResponseAssertion
+const (
+	jmxMatch     = 1
+	jmxContains  = 2
+	jmxNot       = 4
+	jmxEquals    = 8
+	jmxSubstring = 16
+	jmxOr        = 32
+)
+
+// jmxConfigs are the elements which apply to the samplers in their scope, instead of running by themselves
+var jmxConfigs = map[string]bool{
+	"HeaderManager": true, "ConstantTimer": true, "ResponseAssertion": true, "JSONPathAssertion": true,
+	"ConfigTestElement": true, "Arguments": true, "CSVDataSet": true,
+}
+
+// jmxVariablePattern matches the variables and the functions of JMeter like '${host}' and '${__UUID()}'
+var jmxVariablePattern = regexp.MustCompile(`\$\{([^{}]+)\}`)
+
+// jmxElement is an element of a JMX file, the test elements and their properties alike
+type jmxElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr    `xml:",any,attr"`
+	Text     string        `xml:",chardata"`
+	Children []*jmxElement `xml:",any"`
+}
+
+func (e *jmxElement) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (e *jmxElement) enabled() bool {
+	return e.attr("enabled") != "false"
+}
+
+// label names the element in the warnings, like "HTTPSamplerProxy 'Create user'"
+func (e *jmxElement) label() string {
+	return fmt.Sprintf("%s '%s'", e.XMLName.Local, e.attr("testname"))
+}
+
+// prop returns the property of the element, like <stringProp name="HTTPSampler.path">, nil if it is absent
+func (e *jmxElement) prop(name string) *jmxElement {
+	if e == nil {
+		return nil
+	}
+	for _, c := range e.Children {
+		if c.attr("name") == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (e *jmxElement) str(name string) string {
+	if p := e.prop(name); p != nil {
+		return p.Text
+	}
+	return ""
+}
+
+func (e *jmxElement) boolean(name string, defaultValue bool) bool {
+	p := e.prop(name)
+	if p == nil {
+		return defaultValue
+	}
+	return strings.TrimSpace(p.Text) == "true"
+}
+
+// items returns the items of a collection property
+func (e *jmxElement) items(name string) []*jmxElement {
+	if p := e.prop(name); p != nil {
+		return p.Children
+	}
+	return nil
+}
+
+// jmxEntry is a test element and the hashTree of its children
+type jmxEntry struct {
+	element *jmxElement
+	tree    *jmxElement
+}
+
+// entries pairs the test elements of a hashTree with the hashTrees following them, which hold their children
+func (e *jmxElement) entries() []jmxEntry {
+	var result []jmxEntry
+	for i := 0; i < len(e.Children); i++ {
+		if e.Children[i].XMLName.Local == "hashTree" {
+			continue
+		}
+		entry := jmxEntry{element: e.Children[i], tree: &jmxElement{}}
+		if i+1 < len(e.Children) && e.Children[i+1].XMLName.Local == "hashTree" {
+			entry.tree = e.Children[i+1]
+			i++
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// jmxScope is what the config elements, the timers and the assertions apply to the samplers in their scope
+type jmxScope struct {
+	headers []string
+	delay   time.Duration
+	// assertions and defaults are from the outermost to the innermost
+	assertions []*jmxElement
+	defaults   []*jmxElement
+}
+
+// LoadJMX reads a JMeter test plan
+func LoadJMX(path string, options JMXOptions) (*JMXPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJMX(data, options)
+}
+
+// ParseJMX converts the common subset of a JMeter test plan: the HTTP samplers of a thread group become the steps of a scenario
+// in order, with the headers of the header managers, the delays of the constant timers, and the response and JSON path
+// assertions in their scope. the threads, the loops and the ramp-up of the thread group, and the CSV data set, become the
+// options of the run. the user defined variables are replaced by their values, the variables of the data set are kept like
+// '${name}', and the common functions like '${__UUID()}' become the template functions.
+// the elements which are not converted, or converted partially, are listed in the warnings
+func ParseJMX(data []byte, options JMXOptions) (*JMXPlan, error) {
+	root := &jmxElement{}
+	if err := xml.Unmarshal(data, root); err != nil {
+		return nil, fmt.Errorf("invalid JMX: %w", err)
+	}
+	if root.XMLName.Local != "jmeterTestPlan" {
+		return nil, errors.New("invalid JMX: the root element should be jmeterTestPlan")
+	}
+	var testPlan *jmxEntry
+	for _, c := range root.Children {
+		if c.XMLName.Local != "hashTree" {
+			continue
+		}
+		for _, entry := range c.entries() {
+			if entry.element.XMLName.Local == "TestPlan" {
+				testPlan = &entry
+				break
+			}
+		}
+	}
+	if testPlan == nil {
+		return nil, errors.New("invalid JMX: the TestPlan is not found")
+	}
+	c := &jmxConverter{
+		vars:   make(map[string]string, 8),
+		names:  make(stepNames, 16),
+		warned: make(map[string]bool, 8),
+		plan:   &JMXPlan{Scenario: &task.Scenario{Name: testPlan.element.attr("testname")}},
+	}
+	c.define(testPlan.element.prop("TestPlan.user_defined_variables"))
+	entries := testPlan.tree.entries()
+	scope := c.configure(entries, jmxScope{})
+	var group *jmxEntry
+	for i, entry := range entries {
+		e := entry.element
+		if !e.enabled() || jmxConfigs[e.XMLName.Local] {
+			continue
+		}
+		if e.XMLName.Local != "ThreadGroup" {
+			c.unsupported(e)
+			continue
+		}
+		if group == nil && (options.ThreadGroup == "" || strings.EqualFold(e.attr("testname"), options.ThreadGroup)) {
+			group = &entries[i]
+			continue
+		}
+		c.warn("%s is not converted, only 1 thread group is converted", e.label())
+	}
+	if group == nil {
+		if options.ThreadGroup != "" {
+			return nil, fmt.Errorf("the thread group '%s' is not found", options.ThreadGroup)
+		}
+		return nil, errors.New("no enabled thread group is found")
+	}
+	c.threadGroup(group.element)
+	c.walk(group.tree, scope)
+	if len(c.plan.Scenario.Steps) == 0 {
+		return nil, fmt.Errorf("no HTTP samplers are found in %s", group.element.label())
+	}
+	return c.plan, nil
+}
+
+type jmxConverter struct {
+	// vars are the user defined variables
+	vars   map[string]string
+	names  stepNames
+	warned map[string]bool
+	plan   *JMXPlan
+}
+
+// warn adds a warning once
+func (c *jmxConverter) warn(format string, args ...interface{}) {
+	w := fmt.Sprintf(format, args...)
+	if !c.warned[w] {
+		c.warned[w] = true
+		c.plan.Warnings = append(c.plan.Warnings, w)
+	}
+}
+
+func (c *jmxConverter) unsupported(e *jmxElement) {
+	c.warn("%s is not supported", e.label())
+}
+
+// replace replaces the user defined variables and the functions in s
+func (c *jmxConverter) replace(s string) string {
+	return jmxVariablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		if strings.HasPrefix(name, "__") {
+			return c.function(name[2:], ref)
+		}
+		if value, ok := c.vars[name]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// function turns a function of JMeter into a template function, it is kept as it is if it is not supported
+func (c *jmxConverter) function(call string, ref string) string {
+	name, params := call, []string(nil)
+	if i := strings.Index(call, "("); i >= 0 && strings.HasSuffix(call, ")") {
+		name = call[:i]
+		if args := strings.TrimSpace(call[i+1 : len(call)-1]); args != "" {
+			params = strings.Split(args, ",")
+			for j := range params {
+				params[j] = strings.TrimSpace(params[j])
+			}
+		}
+	}
+	isInt := func(s string) bool {
+		_, err := strconv.Atoi(s)
+		return err == nil
+	}
+	switch name {
+	case "UUID":
+		return "{{uuid}}"
+	case "Random":
+		if len(params) >= 2 && isInt(params[0]) && isInt(params[1]) {
+			return fmt.Sprintf("{{randInt %s %s}}", params[0], params[1])
+		}
+	case "RandomString":
+		if len(params) >= 1 && isInt(params[0]) {
+			if len(params) >= 2 && params[1] != "" {
+				c.warn("the characters of '%s' are not supported, it is replaced by random letters", ref)
+			}
+			return fmt.Sprintf("{{randString %s}}", params[0])
+		}
+	case "time":
+		if len(params) == 0 {
+			return "{{unixMillis}}"
+		}
+		if params[0] == "/1000" {
+			return "{{unix}}"
+		}
+	case "threadNum":
+		return "{{workerID}}"
+	case "counter":
+		return "{{seq}}"
+	case "P", "property":
+		if len(params) >= 2 {
+			c.warn("the property in '%s' is replaced by its default value '%s'", ref, params[1])
+			return params[1]
+		}
+	}
+	c.warn("the function '%s' is not supported, it is sent as it is", ref)
+	return ref
+}
+
+// define adds the user defined variables in the arguments, which may refer to those defined before them
+func (c *jmxConverter) define(arguments *jmxElement) {
+	for _, a := range arguments.items("Arguments.arguments") {
+		c.vars[a.str("Argument.name")] = c.replace(a.str("Argument.value"))
+	}
+}
+
+// number returns the number in the property of the element
+func (c *jmxConverter) number(e *jmxElement, name string, defaultValue int) int {
+	text := strings.TrimSpace(c.replace(e.str(name)))
+	if text == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		c.warn("the %s '%s' is not a number, %d is used", name, text, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+// threadGroup converts the load of the thread group
+func (c *jmxConverter) threadGroup(g *jmxElement) {
+	p := c.plan
+	p.Concurrency = c.number(g, "ThreadGroup.num_threads", 1)
+	p.RampUp = time.Duration(c.number(g, "ThreadGroup.ramp_time", 0)) * time.Second
+	loop := c.number(g.prop("ThreadGroup.main_controller"), "LoopController.loops", 1)
+	if loop > 0 {
+		p.Loop = loop
+	}
+	if g.boolean("ThreadGroup.scheduler", false) {
+		p.Duration = time.Duration(c.number(g, "ThreadGroup.duration", 0)) * time.Second
+		if c.number(g, "ThreadGroup.delay", 0) > 0 {
+			c.warn("the startup delay of %s is not supported", g.label())
+		}
+	}
+	if p.Duration > 0 && p.Loop > 0 {
+		c.warn("the loops of %s are dropped, the run is limited by its duration", g.label())
+		p.Loop = 0
+	}
+	if p.Duration == 0 && p.Loop == 0 {
+		c.warn("%s loops forever, the duration of %s is assumed", g.label(), jmxForever)
+		p.Duration = jmxForever
+	}
+	if p.RampUp > 0 && p.Duration == 0 {
+		c.warn("the ramp-up of %s is dropped, it is supported with a duration only", g.label())
+		p.RampUp = 0
+	}
+}
+
+// configure applies the config elements, the timers and the assertions among the entries to a copy of the scope
+func (c *jmxConverter) configure(entries []jmxEntry, parent jmxScope) jmxScope {
+	scope := jmxScope{
+		headers:    append([]string{}, parent.headers...),
+		delay:      parent.delay,
+		assertions: append([]*jmxElement{}, parent.assertions...),
+		defaults:   append([]*jmxElement{}, parent.defaults...),
+	}
+	// the variables are defined before the other elements use them
+	for _, entry := range entries {
+		if e := entry.element; e.enabled() && e.XMLName.Local == "Arguments" {
+			c.define(e)
+		}
+	}
+	for _, entry := range entries {
+		e := entry.element
+		if !e.enabled() {
+			continue
+		}
+		switch e.XMLName.Local {
+		case "HeaderManager":
+			for _, h := range e.items("HeaderManager.headers") {
+				name := c.replace(h.str("Header.name"))
+				header := name + ": " + c.replace(h.str("Header.value"))
+				replaced := false
+				for i := range scope.headers {
+					if strings.EqualFold(headerName(scope.headers[i]), name) {
+						scope.headers[i] = header
+						replaced = true
+					}
+				}
+				if !replaced {
+					scope.headers = append(scope.headers, header)
+				}
+			}
+		case "ConstantTimer":
+			scope.delay += time.Duration(c.number(e, "ConstantTimer.delay", 0)) * time.Millisecond
+		case "ResponseAssertion", "JSONPathAssertion":
+			scope.assertions = append(scope.assertions, e)
+		case "ConfigTestElement":
+			if e.attr("guiclass") != "HttpDefaultsGui" {
+				c.unsupported(e)
+				continue
+			}
+			scope.defaults = append(scope.defaults, e)
+		case "CSVDataSet":
+			c.dataSet(e)
+		}
+	}
+	return scope
+}
+
+// walk converts the samplers in the tree in order, the children of the controllers are converted as they are in place
+func (c *jmxConverter) walk(tree *jmxElement, parent jmxScope) {
+	entries := tree.entries()
+	scope := c.configure(entries, parent)
+	for _, entry := range entries {
+		e := entry.element
+		if !e.enabled() || jmxConfigs[e.XMLName.Local] {
+			continue
+		}
+		switch name := e.XMLName.Local; {
+		case name == "HTTPSamplerProxy":
+			c.sampler(e, entry.tree, scope)
+		case name == "GenericController" || name == "TransactionController":
+			c.walk(entry.tree, scope)
+		case strings.HasSuffix(name, "Controller"):
+			c.warn("%s is not supported, its children are converted as if it were a simple controller", e.label())
+			c.walk(entry.tree, scope)
+		default:
+			c.unsupported(e)
+		}
+	}
+}
+
+// sampler converts an HTTP sampler into a step, the missing parts of its url are taken from the HTTP request defaults
+func (c *jmxConverter) sampler(e *jmxElement, tree *jmxElement, parent jmxScope) {
+	entries := tree.entries()
+	scope := c.configure(entries, parent)
+	for _, entry := range entries {
+		if child := entry.element; child.enabled() && !jmxConfigs[child.XMLName.Local] {
+			c.unsupported(child)
+		}
+	}
+	field := func(name string) string {
+		value := e.str("HTTPSampler." + name)
+		for i := len(scope.defaults) - 1; value == "" && i >= 0; i-- {
+			value = scope.defaults[i].str("HTTPSampler." + name)
+		}
+		return strings.TrimSpace(c.replace(value))
+	}
+	step := task.Step{Method: strings.ToUpper(field("method"))}
+	if step.Method == "" {
+		step.Method = http.MethodGet
+	}
+	path := field("path")
+	if lower := strings.ToLower(path); strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		step.URL = path
+	} else {
+		domain := field("domain")
+		if domain == "" {
+			c.warn("%s is not converted, its server name is missing", e.label())
+			return
+		}
+		protocol := field("protocol")
+		if protocol == "" {
+			protocol = "http"
+		}
+		step.URL = protocol + "://" + domain
+		if port := field("port"); port != "" {
+			step.URL += ":" + port
+		}
+		if path != "" && !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		step.URL += path
+	}
+	step.Name = c.names.unique(e.attr("testname"))
+	if len(scope.headers) > 0 {
+		step.Headers = scope.headers
+	}
+	arguments := e.prop("HTTPsampler.Arguments").items("Arguments.arguments")
+	if e.boolean("HTTPSampler.postBodyRaw", false) {
+		if len(arguments) > 0 {
+			step.Body = c.replace(arguments[0].str("Argument.value"))
+		}
+	} else if len(arguments) > 0 {
+		fields := make([]string, 0, len(arguments))
+		for _, a := range arguments {
+			name, value := c.replace(a.str("Argument.name")), c.replace(a.str("Argument.value"))
+			if a.boolean("HTTPArgument.always_encode", false) {
+				name, value = escapeValue(name), escapeValue(value)
+			}
+			fields = append(fields, name+"="+value)
+		}
+		switch step.Method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+			sep := "?"
+			if strings.Contains(step.URL, "?") {
+				sep = "&"
+			}
+			step.URL += sep + strings.Join(fields, "&")
+		default:
+			step.Body = strings.Join(fields, "&")
+			if !hasHeader(step.Headers, "Content-Type") {
+				step.Headers = append(append([]string{}, step.Headers...), "Content-Type: application/x-www-form-urlencoded")
+			}
+		}
+	}
+	if e.boolean("HTTPSampler.DO_MULTIPART_POST", false) || len(e.prop("HTTPsampler.Files").items("HTTPFileArgs.files")) > 0 {
+		c.warn("the multipart form and the files of %s are not supported", e.label())
+	}
+	if timeout := c.number(e, "HTTPSampler.response_timeout", 0); timeout > 0 {
+		step.Timeout = time.Duration(timeout) * time.Millisecond
+	}
+	step.Delay = scope.delay
+	// the nearest assertions win, since the scenario has one assertion of each kind for a step
+	for i := len(scope.assertions) - 1; i >= 0; i-- {
+		if a := scope.assertions[i]; a.XMLName.Local == "ResponseAssertion" {
+			c.responseAssertion(&step, a)
+		} else {
+			c.jsonPathAssertion(&step, a)
+		}
+	}
+	c.plan.Scenario.Steps = append(c.plan.Scenario.Steps, step)
+}
+
+// responseAssertion converts a response assertion of the status code or the body
+func (c *jmxConverter) responseAssertion(step *task.Step, a *jmxElement) {
+	testType, _ := strconv.Atoi(strings.TrimSpace(a.str("Assertion.test_type")))
+	if testType&jmxNot != 0 {
+		c.warn("the negated %s is not supported", a.label())
+		return
+	}
+	var patterns []string
+	// the typo is how JMeter names it
+	for _, s := range a.items("Asserion.test_strings") {
+		patterns = append(patterns, c.replace(s.Text))
+	}
+	if len(patterns) == 0 {
+		return
+	}
+	switch field := a.str("Assertion.test_field"); field {
+	case "Assertion.response_code":
+		codes := make([]int, 0, len(patterns))
+		for _, p := range patterns {
+			code, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil || code < 100 || code > 999 {
+				c.warn("%s is not supported, only the exact status codes like 200 are", a.label())
+				return
+			}
+			codes = append(codes, code)
+		}
+		if step.Assert.StatusCodes != nil {
+			c.warn("%s is not converted for some samplers, only the nearest assertion of the status code is", a.label())
+			return
+		}
+		step.Assert.StatusCodes = codes
+	case "Assertion.response_data", "":
+		for i, p := range patterns {
+			switch {
+			case testType&jmxEquals != 0:
+				patterns[i] = "^" + regexp.QuoteMeta(p) + "$"
+			case testType&jmxSubstring != 0:
+				patterns[i] = regexp.QuoteMeta(p)
+			case testType&jmxMatch != 0:
+				patterns[i] = "^(?:" + p + ")$"
+			}
+		}
+		pattern := patterns[0]
+		if len(patterns) > 1 {
+			if testType&jmxOr == 0 {
+				c.warn("only the first pattern of %s is converted", a.label())
+			} else {
+				pattern = "(?:" + strings.Join(patterns, ")|(?:") + ")"
+			}
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			c.warn("%s is not supported: %v", a.label(), err)
+			return
+		}
+		if step.Assert.Regex != "" {
+			c.warn("%s is not converted for some samplers, only the nearest assertion of the body is", a.label())
+			return
+		}
+		step.Assert.Regex = pattern
+	default:
+		c.warn("%s is not supported, only the status code and the body can be tested, but not '%s'", a.label(), field)
+	}
+}
+
+// jsonPathAssertion converts a JSON path assertion, which tests that the path exists or equals the expected value
+func (c *jmxConverter) jsonPathAssertion(step *task.Step, a *jmxElement) {
+	path := strings.TrimSpace(c.replace(a.str("JSON_PATH")))
+	if path == "" {
+		return
+	}
+	if a.boolean("INVERT", false) || a.boolean("EXPECT_NULL", false) {
+		c.warn("the inverted or null %s is not supported", a.label())
+		return
+	}
+	expression := path + " exists"
+	if a.boolean("JSONVALIDATION", false) {
+		expected := c.replace(a.str("EXPECTED_VALUE"))
+		if expected == "" || strings.Contains(expected, " ") || (a.boolean("ISREGEX", true) && regexp.QuoteMeta(expected) != expected) {
+			c.warn("%s is not supported, only the expected values without spaces and regex characters are", a.label())
+			return
+		}
+		expression = path + " == " + expected
+	}
+	if step.Assert.JSON != "" {
+		c.warn("%s is not converted for some samplers, only the nearest JSON assertion is", a.label())
+		return
+	}
+	step.Assert.JSON = expression
+}
+
+// dataSet converts a CSV data set into the data file of the run
+func (c *jmxConverter) dataSet(e *jmxElement) {
+	filename := c.replace(e.str("filename"))
+	if c.plan.DataFile != "" && c.plan.DataFile != filename {
+		c.warn("%s is not converted, only 1 data file is supported", e.label())
+		return
+	}
+	c.plan.DataFile = filename
+	c.plan.StopOnData = !e.boolean("recycle", true) || e.boolean("stopThread", false)
+	if d := e.str("delimiter"); d != "" && d != "," {
+		c.warn("the delimiter '%s' of %s is not supported, the data file should be separated by commas", d, e.label())
+	}
+	if names := strings.TrimSpace(e.str("variableNames")); names != "" && !e.boolean("ignoreFirstLine", false) {
+		c.warn("the data file %s should start with the header line '%s', which are the names of the variables", filename, names)
+	}
+}
+
+// RunArgs returns the arguments of 'httptester run' to run the scenario file with the load of the test plan
+func (p *JMXPlan) RunArgs(scenarioFile string) []string {
+	args := []string{"run", "-f", scenarioFile}
+	concurrency := strconv.Itoa(p.Concurrency)
+	switch {
+	case p.Duration > 0 && p.RampUp > 0:
+		// the threads are ramped up from 1, and kept for the rest of the duration
+		stages := fmt.Sprintf("%s:%d", p.Duration, p.Concurrency)
+		if p.RampUp < p.Duration {
+			stages = fmt.Sprintf("%s:%d,%s:%d", p.RampUp, p.Concurrency, p.Duration-p.RampUp, p.Concurrency)
+		}
+		args = append(args, "--concurrency", "1", "--stages", stages)
+	case p.Duration > 0:
+		args = append(args, "--concurrency", concurrency, "--duration", p.Duration.String())
+	default:
+		args = append(args, "--concurrency", concurrency, "--loop", strconv.Itoa(p.Loop))
+	}
+	if p.DataFile != "" {
+		args = append(args, "--data", p.DataFile)
+		if p.StopOnData {
+			args = append(args, "--data-exhausted", task.ExhaustedStop)
+		}
+	}
+	return args
+}
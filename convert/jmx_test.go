@@ -0,0 +1,298 @@
+package convert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleJMX = `<?xml version="1.0" encoding="UTF-8"?>
+<jmeterTestPlan version="1.2" properties="5.0" jmeter="5.6.2">
+  <hashTree>
+    <TestPlan guiclass="TestPlanGui" testclass="TestPlan" testname="Users" enabled="true">
+      <elementProp name="TestPlan.user_defined_variables" elementType="Arguments" guiclass="ArgumentsPanel" testclass="Arguments" testname="User Defined Variables" enabled="true">
+        <collectionProp name="Arguments.arguments">
+          <elementProp name="host" elementType="Argument">
+            <stringProp name="Argument.name">host</stringProp>
+            <stringProp name="Argument.value">${__P(host,localhost)}</stringProp>
+          </elementProp>
+          <elementProp name="version" elementType="Argument">
+            <stringProp name="Argument.name">version</stringProp>
+            <stringProp name="Argument.value">v1</stringProp>
+          </elementProp>
+        </collectionProp>
+      </elementProp>
+    </TestPlan>
+    <hashTree>
+      <Arguments guiclass="ArgumentsPanel" testclass="Arguments" testname="Base" enabled="true">
+        <collectionProp name="Arguments.arguments">
+          <elementProp name="base" elementType="Argument">
+            <stringProp name="Argument.name">base</stringProp>
+            <stringProp name="Argument.value">/api/${version}</stringProp>
+          </elementProp>
+        </collectionProp>
+      </Arguments>
+      <hashTree/>
+      <ConfigTestElement guiclass="HttpDefaultsGui" testclass="ConfigTestElement" testname="HTTP Request Defaults" enabled="true">
+        <stringProp name="HTTPSampler.domain">${host}</stringProp>
+        <stringProp name="HTTPSampler.port">1234</stringProp>
+        <stringProp name="HTTPSampler.protocol">http</stringProp>
+      </ConfigTestElement>
+      <hashTree/>
+      <HeaderManager guiclass="HeaderPanel" testclass="HeaderManager" testname="HTTP Header Manager" enabled="true">
+        <collectionProp name="HeaderManager.headers">
+          <elementProp name="" elementType="Header">
+            <stringProp name="Header.name">Accept</stringProp>
+            <stringProp name="Header.value">application/json</stringProp>
+          </elementProp>
+          <elementProp name="" elementType="Header">
+            <stringProp name="Header.name">X-Request-Id</stringProp>
+            <stringProp name="Header.value">${__UUID()}</stringProp>
+          </elementProp>
+        </collectionProp>
+      </HeaderManager>
+      <hashTree/>
+      <ThreadGroup guiclass="ThreadGroupGui" testclass="ThreadGroup" testname="Users" enabled="true">
+        <stringProp name="ThreadGroup.on_sample_error">continue</stringProp>
+        <elementProp name="ThreadGroup.main_controller" elementType="LoopController" guiclass="LoopControlPanel" testclass="LoopController" testname="Loop Controller" enabled="true">
+          <boolProp name="LoopController.continue_forever">false</boolProp>
+          <stringProp name="LoopController.loops">5</stringProp>
+        </elementProp>
+        <stringProp name="ThreadGroup.num_threads">${__P(threads,20)}</stringProp>
+        <stringProp name="ThreadGroup.ramp_time">10</stringProp>
+        <boolProp name="ThreadGroup.scheduler">false</boolProp>
+        <stringProp name="ThreadGroup.duration"></stringProp>
+        <stringProp name="ThreadGroup.delay"></stringProp>
+      </ThreadGroup>
+      <hashTree>
+        <CSVDataSet guiclass="TestBeanGUI" testclass="CSVDataSet" testname="CSV Data Set Config" enabled="true">
+          <stringProp name="filename">users.csv</stringProp>
+          <stringProp name="fileEncoding">UTF-8</stringProp>
+          <stringProp name="variableNames">name,age</stringProp>
+          <boolProp name="ignoreFirstLine">false</boolProp>
+          <stringProp name="delimiter">,</stringProp>
+          <boolProp name="quotedData">false</boolProp>
+          <boolProp name="recycle">true</boolProp>
+          <boolProp name="stopThread">false</boolProp>
+          <stringProp name="shareMode">shareMode.all</stringProp>
+        </CSVDataSet>
+        <hashTree/>
+        <ResponseAssertion guiclass="AssertionGui" testclass="ResponseAssertion" testname="Status 200" enabled="true">
+          <collectionProp name="Asserion.test_strings">
+            <stringProp name="49586">200</stringProp>
+          </collectionProp>
+          <stringProp name="Assertion.custom_message"></stringProp>
+          <stringProp name="Assertion.test_field">Assertion.response_code</stringProp>
+          <boolProp name="Assertion.assume_success">false</boolProp>
+          <intProp name="Assertion.test_type">8</intProp>
+        </ResponseAssertion>
+        <hashTree/>
+        <ConstantTimer guiclass="ConstantTimerGui" testclass="ConstantTimer" testname="Think" enabled="true">
+          <stringProp name="ConstantTimer.delay">300</stringProp>
+        </ConstantTimer>
+        <hashTree/>
+        <HTTPSamplerProxy guiclass="HttpTestSampleGui" testclass="HTTPSamplerProxy" testname="Create user" enabled="true">
+          <boolProp name="HTTPSampler.postBodyRaw">true</boolProp>
+          <elementProp name="HTTPsampler.Arguments" elementType="Arguments">
+            <collectionProp name="Arguments.arguments">
+              <elementProp name="" elementType="HTTPArgument">
+                <boolProp name="HTTPArgument.always_encode">false</boolProp>
+                <stringProp name="Argument.value">{"name": "${name}", "age": ${age}, "code": "${__RandomString(6)}"}</stringProp>
+                <stringProp name="Argument.metadata">=</stringProp>
+              </elementProp>
+            </collectionProp>
+          </elementProp>
+          <stringProp name="HTTPSampler.path">${base}/users</stringProp>
+          <stringProp name="HTTPSampler.method">POST</stringProp>
+          <stringProp name="HTTPSampler.response_timeout">2000</stringProp>
+        </HTTPSamplerProxy>
+        <hashTree>
+          <HeaderManager guiclass="HeaderPanel" testclass="HeaderManager" testname="JSON" enabled="true">
+            <collectionProp name="HeaderManager.headers">
+              <elementProp name="" elementType="Header">
+                <stringProp name="Header.name">Content-Type</stringProp>
+                <stringProp name="Header.value">application/json</stringProp>
+              </elementProp>
+              <elementProp name="" elementType="Header">
+                <stringProp name="Header.name">accept</stringProp>
+                <stringProp name="Header.value">*/*</stringProp>
+              </elementProp>
+            </collectionProp>
+          </HeaderManager>
+          <hashTree/>
+          <ResponseAssertion guiclass="AssertionGui" testclass="ResponseAssertion" testname="Created" enabled="true">
+            <collectionProp name="Asserion.test_strings">
+              <stringProp name="1">200</stringProp>
+              <stringProp name="2">201</stringProp>
+            </collectionProp>
+            <stringProp name="Assertion.test_field">Assertion.response_code</stringProp>
+            <intProp name="Assertion.test_type">40</intProp>
+          </ResponseAssertion>
+          <hashTree/>
+          <JSONPathAssertion guiclass="JSONPathAssertionGui" testclass="JSONPathAssertion" testname="Has id" enabled="true">
+            <stringProp name="JSON_PATH">$.id</stringProp>
+            <stringProp name="EXPECTED_VALUE"></stringProp>
+            <boolProp name="JSONVALIDATION">false</boolProp>
+            <boolProp name="EXPECT_NULL">false</boolProp>
+            <boolProp name="INVERT">false</boolProp>
+            <boolProp name="ISREGEX">true</boolProp>
+          </JSONPathAssertion>
+          <hashTree/>
+          <JSONPostProcessor guiclass="JSONPostProcessorGui" testclass="JSONPostProcessor" testname="Id" enabled="true"/>
+          <hashTree/>
+        </hashTree>
+        <TransactionController guiclass="TransactionControllerGui" testclass="TransactionController" testname="Browse" enabled="true"/>
+        <hashTree>
+          <HTTPSamplerProxy guiclass="HttpTestSampleGui" testclass="HTTPSamplerProxy" testname="List users" enabled="true">
+            <elementProp name="HTTPsampler.Arguments" elementType="Arguments">
+              <collectionProp name="Arguments.arguments">
+                <elementProp name="q" elementType="HTTPArgument">
+                  <boolProp name="HTTPArgument.always_encode">true</boolProp>
+                  <stringProp name="Argument.name">q</stringProp>
+                  <stringProp name="Argument.value">a b</stringProp>
+                </elementProp>
+                <elementProp name="page" elementType="HTTPArgument">
+                  <boolProp name="HTTPArgument.always_encode">true</boolProp>
+                  <stringProp name="Argument.name">page</stringProp>
+                  <stringProp name="Argument.value">${__Random(1,10)}</stringProp>
+                </elementProp>
+              </collectionProp>
+            </elementProp>
+            <stringProp name="HTTPSampler.path">${base}/users</stringProp>
+            <stringProp name="HTTPSampler.method">GET</stringProp>
+          </HTTPSamplerProxy>
+          <hashTree>
+            <ResponseAssertion guiclass="AssertionGui" testclass="ResponseAssertion" testname="Has users" enabled="true">
+              <collectionProp name="Asserion.test_strings">
+                <stringProp name="1">"users": [</stringProp>
+              </collectionProp>
+              <stringProp name="Assertion.test_field">Assertion.response_data</stringProp>
+              <intProp name="Assertion.test_type">16</intProp>
+            </ResponseAssertion>
+            <hashTree/>
+          </hashTree>
+          <IfController guiclass="IfControllerPanel" testclass="IfController" testname="If" enabled="true"/>
+          <hashTree>
+            <HTTPSamplerProxy guiclass="HttpTestSampleGui" testclass="HTTPSamplerProxy" testname="Login" enabled="true">
+              <elementProp name="HTTPsampler.Arguments" elementType="Arguments">
+                <collectionProp name="Arguments.arguments">
+                  <elementProp name="user" elementType="HTTPArgument">
+                    <boolProp name="HTTPArgument.always_encode">false</boolProp>
+                    <stringProp name="Argument.name">user</stringProp>
+                    <stringProp name="Argument.value">${name}</stringProp>
+                  </elementProp>
+                </collectionProp>
+              </elementProp>
+              <stringProp name="HTTPSampler.path">https://auth.example.com/login?from=${__time(yyyyMMdd)}</stringProp>
+              <stringProp name="HTTPSampler.method">POST</stringProp>
+            </HTTPSamplerProxy>
+            <hashTree>
+              <JSONPathAssertion guiclass="JSONPathAssertionGui" testclass="JSONPathAssertion" testname="Token type" enabled="true">
+                <stringProp name="JSON_PATH">$.type</stringProp>
+                <stringProp name="EXPECTED_VALUE">bearer</stringProp>
+                <boolProp name="JSONVALIDATION">true</boolProp>
+              </JSONPathAssertion>
+              <hashTree/>
+            </hashTree>
+          </hashTree>
+          <HTTPSamplerProxy guiclass="HttpTestSampleGui" testclass="HTTPSamplerProxy" testname="Disabled" enabled="false"/>
+          <hashTree/>
+        </hashTree>
+        <ResultCollector guiclass="ViewResultsFullVisualizer" testclass="ResultCollector" testname="View Results Tree" enabled="true"/>
+        <hashTree/>
+      </hashTree>
+      <ThreadGroup guiclass="ThreadGroupGui" testclass="ThreadGroup" testname="Admins" enabled="true">
+        <elementProp name="ThreadGroup.main_controller" elementType="LoopController">
+          <intProp name="LoopController.loops">-1</intProp>
+        </elementProp>
+        <stringProp name="ThreadGroup.num_threads">2</stringProp>
+        <stringProp name="ThreadGroup.ramp_time">30</stringProp>
+        <boolProp name="ThreadGroup.scheduler">true</boolProp>
+        <stringProp name="ThreadGroup.duration">120</stringProp>
+      </ThreadGroup>
+      <hashTree>
+        <HTTPSamplerProxy guiclass="HttpTestSampleGui" testclass="HTTPSamplerProxy" testname="Stats" enabled="true">
+          <stringProp name="HTTPSampler.path">/admin/stats</stringProp>
+        </HTTPSamplerProxy>
+        <hashTree/>
+      </hashTree>
+    </hashTree>
+  </hashTree>
+</jmeterTestPlan>
+`
+
+func TestParseJMX(t *testing.T) {
+	ast := assert.New(t)
+	p, err := ParseJMX([]byte(sampleJMX), JMXOptions{})
+	ast.Nil(err)
+	s := p.Scenario
+	ast.Equal("Users", s.Name)
+	ast.Equal([]string{"Create user", "List users", "Login"}, s.StepNames())
+
+	create := s.Steps[0]
+	ast.Equal("POST", create.Method)
+	ast.Equal("http://localhost:1234/api/v1/users", create.URL)
+	// the header managers of the sampler override those of the test plan
+	ast.Equal([]string{"accept: */*", "X-Request-Id: {{uuid}}", "Content-Type: application/json"}, create.Headers)
+	ast.Equal(`{"name": "${name}", "age": ${age}, "code": "{{randString 6}}"}`, create.Body)
+	ast.Equal(2*time.Second, create.Timeout)
+	ast.Equal(300*time.Millisecond, create.Delay)
+	// the nearest assertion of the status code wins
+	ast.Equal([]int{200, 201}, create.Assert.StatusCodes)
+	ast.Equal("$.id exists", create.Assert.JSON)
+
+	list := s.Steps[1]
+	ast.Equal("GET", list.Method)
+	ast.Equal("http://localhost:1234/api/v1/users?q=a+b&page={{randInt 1 10}}", list.URL)
+	ast.Equal([]string{"Accept: application/json", "X-Request-Id: {{uuid}}"}, list.Headers)
+	ast.Equal([]int{200}, list.Assert.StatusCodes)
+	ast.Equal(`"users": \[`, list.Assert.Regex)
+
+	login := s.Steps[2]
+	ast.Equal("https://auth.example.com/login?from=${__time(yyyyMMdd)}", login.URL)
+	ast.Equal("user=${name}", login.Body)
+	ast.Equal("Content-Type: application/x-www-form-urlencoded", login.Headers[2])
+	ast.Equal("$.type == bearer", login.Assert.JSON)
+	ast.Nil(s.Validate())
+
+	ast.Equal(20, p.Concurrency)
+	ast.Equal(5, p.Loop)
+	ast.Zero(p.RampUp)
+	ast.Equal("users.csv", p.DataFile)
+	ast.Equal([]string{
+		"the property in '${__P(host,localhost)}' is replaced by its default value 'localhost'",
+		"ThreadGroup 'Admins' is not converted, only 1 thread group is converted",
+		"the property in '${__P(threads,20)}' is replaced by its default value '20'",
+		"the ramp-up of ThreadGroup 'Users' is dropped, it is supported with a duration only",
+		"the data file users.csv should start with the header line 'name,age', which are the names of the variables",
+		"JSONPostProcessor 'Id' is not supported",
+		"ResponseAssertion 'Status 200' is not converted for some samplers, only the nearest assertion of the status code is",
+		"IfController 'If' is not supported, its children are converted as if it were a simple controller",
+		"the function '${__time(yyyyMMdd)}' is not supported, it is sent as it is",
+		"ResultCollector 'View Results Tree' is not supported",
+	}, p.Warnings)
+	ast.Equal([]string{"run", "-f", "scenario.yaml", "--concurrency", "20", "--loop", "5", "--data", "users.csv"}, p.RunArgs("scenario.yaml"))
+
+	p, err = ParseJMX([]byte(sampleJMX), JMXOptions{ThreadGroup: "admins"})
+	ast.Nil(err)
+	ast.Equal([]string{"Stats"}, p.Scenario.StepNames())
+	ast.Equal("http://localhost:1234/admin/stats", p.Scenario.Steps[0].URL)
+	ast.Equal(0, p.Loop)
+	ast.Equal([]string{"run", "-f", "scenario.yaml", "--concurrency", "1", "--stages", "30s:2,1m30s:2"}, p.RunArgs("scenario.yaml"))
+
+	_, err = ParseJMX([]byte(sampleJMX), JMXOptions{ThreadGroup: "none"})
+	ast.NotNil(err)
+	_, err = ParseJMX([]byte(`<testResults/>`), JMXOptions{})
+	ast.NotNil(err)
+	_, err = ParseJMX([]byte(`<jmeterTestPlan><hashTree><TestPlan testname="empty"/><hashTree/></hashTree></jmeterTestPlan>`), JMXOptions{})
+	ast.NotNil(err)
+}
+
+func TestJMXRunArgs(t *testing.T) {
+	ast := assert.New(t)
+	p := &JMXPlan{Concurrency: 10, Duration: time.Minute, DataFile: "ids.csv", StopOnData: true}
+	ast.Equal([]string{"run", "-f", "s.yaml", "--concurrency", "10", "--duration", "1m0s", "--data", "ids.csv", "--data-exhausted", "stop"}, p.RunArgs("s.yaml"))
+	p = &JMXPlan{Concurrency: 10, Duration: time.Minute, RampUp: 2 * time.Minute}
+	ast.Equal([]string{"run", "-f", "s.yaml", "--concurrency", "1", "--stages", "1m0s:10"}, p.RunArgs("s.yaml"))
+}
@@ -0,0 +1,683 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rocketk/httptester/task"
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPIOptions are the options of generating a scenario from an OpenAPI specification
+type OpenAPIOptions struct {
+	// Server is the base url of the requests, the first of the servers of the specification is used if it is empty
+	Server string
+	// Tags keeps the operations of these tags only, all of the operations are kept if it is empty
+	Tags []string
+}
+
+type openAPI struct {
+	OpenAPI string `yaml:"openapi"`
+	Servers []struct {
+		URL       string `yaml:"url"`
+		Variables map[string]struct {
+			Default string `yaml:"default"`
+		} `yaml:"variables"`
+	} `yaml:"servers"`
+	// Paths is kept as a node to keep the order of the paths
+	Paths      yaml.Node `yaml:"paths"`
+	Components struct {
+		Schemas       map[string]*schema      `yaml:"schemas"`
+		Parameters    map[string]*parameter   `yaml:"parameters"`
+		RequestBodies map[string]*requestBody `yaml:"requestBodies"`
+		Responses     map[string]*response    `yaml:"responses"`
+	} `yaml:"components"`
+}
+
+type pathItem struct {
+	Parameters []*parameter `yaml:"parameters"`
+	Get        *operation   `yaml:"get"`
+	Post       *operation   `yaml:"post"`
+	Put        *operation   `yaml:"put"`
+	Patch      *operation   `yaml:"patch"`
+	Delete     *operation   `yaml:"delete"`
+	Head       *operation   `yaml:"head"`
+	Options    *operation   `yaml:"options"`
+}
+
+type operation struct {
+	OperationID string               `yaml:"operationId"`
+	Tags        []string             `yaml:"tags"`
+	Parameters  []*parameter         `yaml:"parameters"`
+	RequestBody *requestBody         `yaml:"requestBody"`
+	Responses   map[string]*response `yaml:"responses"`
+}
+
+type parameter struct {
+	Ref      string                 `yaml:"$ref"`
+	Name     string                 `yaml:"name"`
+	In       string                 `yaml:"in"`
+	Required bool                   `yaml:"required"`
+	Schema   *schema                `yaml:"schema"`
+	Example  interface{}            `yaml:"example"`
+	Examples map[string]exampleItem `yaml:"examples"`
+}
+
+type requestBody struct {
+	Ref     string                `yaml:"$ref"`
+	Content map[string]*mediaType `yaml:"content"`
+}
+
+type response struct {
+	Ref     string                `yaml:"$ref"`
+	Content map[string]*mediaType `yaml:"content"`
+}
+
+type mediaType struct {
+	Schema   *schema                `yaml:"schema"`
+	Example  interface{}            `yaml:"example"`
+	Examples map[string]exampleItem `yaml:"examples"`
+}
+
+type exampleItem struct {
+	Value interface{} `yaml:"value"`
+}
+
+type schema struct {
+	Ref        string        `yaml:"$ref"`
+	Type       string        `yaml:"type"`
+	Format     string        `yaml:"format"`
+	Example    interface{}   `yaml:"example"`
+	Default    interface{}   `yaml:"default"`
+	Enum       []interface{} `yaml:"enum"`
+	Minimum    *float64      `yaml:"minimum"`
+	Maximum    *float64      `yaml:"maximum"`
+	MinLength  int           `yaml:"minLength"`
+	Items      *schema       `yaml:"items"`
+	Properties properties    `yaml:"properties"`
+	AllOf      []*schema     `yaml:"allOf"`
+	OneOf      []*schema     `yaml:"oneOf"`
+	AnyOf      []*schema     `yaml:"anyOf"`
+}
+
+// properties keeps the order of the properties of a schema, so that the generated bodies are stable
+type properties struct {
+	names   []string
+	schemas map[string]*schema
+}
+
+func (p *properties) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: properties should be a mapping", node.Line)
+	}
+	p.schemas = make(map[string]*schema, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		s := &schema{}
+		if err := node.Content[i+1].Decode(s); err != nil {
+			return err
+		}
+		p.names = append(p.names, node.Content[i].Value)
+		p.schemas[node.Content[i].Value] = s
+	}
+	return nil
+}
+
+// maxSchemaDepth stops following the references which refer to each other
+const maxSchemaDepth = 6
+
+// LoadOpenAPI reads an OpenAPI 3 specification in YAML or JSON from a file or a url,
+// the relative url of its server is resolved against the url of the specification
+func LoadOpenAPI(location string, options OpenAPIOptions) (*task.Scenario, error) {
+	var data []byte
+	var err error
+	base := ""
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		base = location
+		var resp *http.Response
+		if resp, err = http.Get(location); err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to get the specification %s: %s", location, resp.Status)
+		}
+		data, err = io.ReadAll(resp.Body)
+	} else {
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseOpenAPI(data, base, options)
+}
+
+// ParseOpenAPI generates a scenario with a step for each operation of an OpenAPI 3 specification, in the order of the paths.
+// the parameters and the bodies are filled from the examples, or the fake values of their schemas made by the template functions,
+// and the success status codes declared by the responses are asserted. if a POST to a collection like '/users' returns an id,
+// it is extracted and used by the operations on the items like '/users/{id}', so that they work on what the scenario created
+func ParseOpenAPI(data []byte, options OpenAPIOptions) (*task.Scenario, error) {
+	return parseOpenAPI(data, "", options)
+}
+
+func parseOpenAPI(data []byte, base string, options OpenAPIOptions) (*task.Scenario, error) {
+	spec := &openAPI{}
+	if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI specification: %w", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("only OpenAPI 3 is supported, but the version is '%s'", spec.OpenAPI)
+	}
+	server, err := spec.server(options.Server, base)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{spec: spec, created: make(map[string]string, 4)}
+	s := &task.Scenario{Steps: make([]task.Step, 0, 16)}
+	for i := 0; i+1 < len(spec.Paths.Content); i += 2 {
+		path := spec.Paths.Content[i].Value
+		item := &pathItem{}
+		if err := spec.Paths.Content[i+1].Decode(item); err != nil {
+			return nil, fmt.Errorf("invalid path '%s': %w", path, err)
+		}
+		// the collection is created before its items are read, updated and deleted
+		for _, m := range []struct {
+			method string
+			op     *operation
+		}{
+			{http.MethodPost, item.Post}, {http.MethodGet, item.Get}, {http.MethodPut, item.Put}, {http.MethodPatch, item.Patch},
+			{http.MethodHead, item.Head}, {http.MethodOptions, item.Options}, {http.MethodDelete, item.Delete},
+		} {
+			if m.op == nil || !matchTags(m.op.Tags, options.Tags) {
+				continue
+			}
+			step, err := g.step(server, path, m.method, item.Parameters, m.op)
+			if err != nil {
+				return nil, fmt.Errorf("invalid operation %s %s: %w", m.method, path, err)
+			}
+			s.Steps = append(s.Steps, step)
+		}
+	}
+	if len(s.Steps) == 0 {
+		return nil, errors.New("no operations are found in the specification")
+	}
+	return s, nil
+}
+
+// server returns the base url of the requests without the trailing slash
+func (spec *openAPI) server(server string, base string) (string, error) {
+	if server == "" {
+		if len(spec.Servers) == 0 {
+			return "", errors.New("no servers in the specification, the server should be given")
+		}
+		server = spec.Servers[0].URL
+		for name, v := range spec.Servers[0].Variables {
+			server = strings.ReplaceAll(server, "{"+name+"}", v.Default)
+		}
+	}
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("invalid server '%s': %w", server, err)
+	}
+	if !u.IsAbs() {
+		if base == "" {
+			return "", fmt.Errorf("the server '%s' is relative, an absolute one should be given", server)
+		}
+		b, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		u = b.ResolveReference(u)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+func matchTags(tags []string, wanted []string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		if containsFold(wanted, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// generator makes the steps of the operations
+type generator struct {
+	spec *openAPI
+	// created is the variable of the id extracted from the response of a POST to the collection, by the path of the collection
+	created map[string]string
+}
+
+func (g *generator) step(server string, path string, method string, common []*parameter, op *operation) (task.Step, error) {
+	step := task.Step{Name: op.OperationID, Method: method}
+	if step.Name == "" {
+		step.Name = method + " " + path
+	}
+	params, err := g.parameters(common, op.Parameters)
+	if err != nil {
+		return step, err
+	}
+	resolved := path
+	query := make([]string, 0, 4)
+	for _, p := range params {
+		switch p.In {
+		case "path":
+			value := g.pathValue(path, p)
+			resolved = strings.ReplaceAll(resolved, "{"+p.Name+"}", value)
+		case "query":
+			if p.Required || p.Example != nil || len(p.Examples) > 0 {
+				query = append(query, url.QueryEscape(p.Name)+"="+escapeValue(g.value(p.Schema, p.Example, p.Examples)))
+			}
+		case "header":
+			if p.Required {
+				step.Headers = append(step.Headers, p.Name+": "+g.value(p.Schema, p.Example, p.Examples))
+			}
+		}
+	}
+	step.URL = server + resolved
+	if len(query) > 0 {
+		step.URL += "?" + strings.Join(query, "&")
+	}
+	if op.RequestBody != nil {
+		body, err := g.resolveRequestBody(op.RequestBody)
+		if err != nil {
+			return step, err
+		}
+		if m, ok := body.Content["application/json"]; ok {
+			step.Headers = append(step.Headers, "Content-Type: application/json")
+			step.Body, _ = g.json(m.Schema, mediaExample(m), nil)
+		} else if m, ok := body.Content["application/x-www-form-urlencoded"]; ok {
+			step.Headers = append(step.Headers, "Content-Type: application/x-www-form-urlencoded")
+			step.Body = g.form(m.Schema)
+		}
+	}
+	step.Assert.StatusCodes = successCodes(op.Responses)
+	if method == http.MethodPost && !strings.HasSuffix(path, "}") {
+		if name, ok := g.createdID(op.Responses); ok {
+			step.Extract = []task.Extractor{{Name: g.variableOf(path), JSON: "$." + name}}
+		}
+	}
+	return step, nil
+}
+
+// pathValue returns the variable extracted from the POST to the collection if the parameter is the last segment of the path
+func (g *generator) pathValue(path string, p *parameter) string {
+	if collection := strings.TrimSuffix(path, "/{"+p.Name+"}"); collection != path {
+		if variable, ok := g.created[collection]; ok {
+			return "${" + variable + "}"
+		}
+	}
+	return escapePathValue(g.value(p.Schema, p.Example, p.Examples))
+}
+
+// variableOf names the variable of the id created in the collection, e.g. 'usersId' for '/users', and remembers it
+func (g *generator) variableOf(collection string) string {
+	segments := strings.Split(strings.Trim(collection, "/"), "/")
+	name := "id"
+	if last := segments[len(segments)-1]; last != "" {
+		name = strings.Map(func(r rune) rune {
+			if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+				return r
+			}
+			return '_'
+		}, last) + "Id"
+	}
+	g.created[collection] = name
+	return name
+}
+
+// createdID returns the id property of the JSON object returned by a successful response
+func (g *generator) createdID(responses map[string]*response) (string, bool) {
+	for _, code := range sortedKeys(responses) {
+		if !strings.HasPrefix(code, "2") {
+			continue
+		}
+		r, err := g.resolveResponse(responses[code])
+		if err != nil || r.Content["application/json"] == nil {
+			continue
+		}
+		s := g.resolve(r.Content["application/json"].Schema)
+		if s != nil && s.Properties.schemas["id"] != nil {
+			return "id", true
+		}
+	}
+	return "", false
+}
+
+// successCodes returns the declared status codes from 200 to 399 in order
+func successCodes(responses map[string]*response) []int {
+	codes := make([]int, 0, 2)
+	for code := range responses {
+		if n, err := strconv.Atoi(code); err == nil && n >= 200 && n < 400 {
+			codes = append(codes, n)
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// parameters resolves the parameters, those of the operation override those of the path with the same name and location
+func (g *generator) parameters(common []*parameter, own []*parameter) ([]*parameter, error) {
+	result := make([]*parameter, 0, len(common)+len(own))
+	index := make(map[string]int, len(common)+len(own))
+	for _, p := range append(append([]*parameter{}, common...), own...) {
+		resolved, err := g.resolveParameter(p)
+		if err != nil {
+			return nil, err
+		}
+		key := resolved.In + " " + resolved.Name
+		if i, ok := index[key]; ok {
+			result[i] = resolved
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, resolved)
+	}
+	return result, nil
+}
+
+// refName returns the name of a local reference like '#/components/schemas/User'
+func refName(ref string, kind string) (string, error) {
+	prefix := "#/components/" + kind + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("unsupported reference '%s'", ref)
+	}
+	return strings.TrimPrefix(ref, prefix), nil
+}
+
+func (g *generator) resolveParameter(p *parameter) (*parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	name, err := refName(p.Ref, "parameters")
+	if err != nil {
+		return nil, err
+	}
+	if resolved, ok := g.spec.Components.Parameters[name]; ok {
+		return resolved, nil
+	}
+	return nil, fmt.Errorf("the reference '%s' is not found", p.Ref)
+}
+
+func (g *generator) resolveRequestBody(b *requestBody) (*requestBody, error) {
+	if b.Ref == "" {
+		return b, nil
+	}
+	name, err := refName(b.Ref, "requestBodies")
+	if err != nil {
+		return nil, err
+	}
+	if resolved, ok := g.spec.Components.RequestBodies[name]; ok {
+		return resolved, nil
+	}
+	return nil, fmt.Errorf("the reference '%s' is not found", b.Ref)
+}
+
+func (g *generator) resolveResponse(r *response) (*response, error) {
+	if r == nil || r.Ref == "" {
+		return r, nil
+	}
+	name, err := refName(r.Ref, "responses")
+	if err != nil {
+		return nil, err
+	}
+	if resolved, ok := g.spec.Components.Responses[name]; ok {
+		return resolved, nil
+	}
+	return nil, fmt.Errorf("the reference '%s' is not found", r.Ref)
+}
+
+// resolve follows the reference of the schema, and merges the schemas of allOf, it returns nil if the reference is not found
+func (g *generator) resolve(s *schema) *schema {
+	for i := 0; s != nil && s.Ref != "" && i < maxSchemaDepth; i++ {
+		name, err := refName(s.Ref, "schemas")
+		if err != nil {
+			return nil
+		}
+		s = g.spec.Components.Schemas[name]
+	}
+	if s == nil || len(s.AllOf) == 0 {
+		return s
+	}
+	merged := *s
+	merged.AllOf = nil
+	own := merged
+	merged.Properties = properties{schemas: make(map[string]*schema, 8)}
+	for _, part := range append(append([]*schema{}, s.AllOf...), &own) {
+		part = g.resolve(part)
+		if part == nil {
+			continue
+		}
+		if merged.Type == "" {
+			merged.Type = part.Type
+		}
+		for _, name := range part.Properties.names {
+			if _, ok := merged.Properties.schemas[name]; !ok {
+				merged.Properties.names = append(merged.Properties.names, name)
+			}
+			merged.Properties.schemas[name] = part.Properties.schemas[name]
+		}
+	}
+	return &merged
+}
+
+func mediaExample(m *mediaType) interface{} {
+	if m.Example != nil {
+		return m.Example
+	}
+	return firstExample(m.Examples)
+}
+
+// firstExample returns the value of the first example by the name, to be stable
+func firstExample(examples map[string]exampleItem) interface{} {
+	for _, name := range sortedKeys(examples) {
+		return examples[name].Value
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// example returns the first of the example, the default and the first of the enum of the schema
+func example(s *schema) interface{} {
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	}
+	return nil
+}
+
+// value returns a parameter by the examples, or a fake value of its schema
+func (g *generator) value(s *schema, ex interface{}, examples map[string]exampleItem) string {
+	if ex == nil {
+		ex = firstExample(examples)
+	}
+	if ex != nil {
+		return task.StringOf(normalize(ex))
+	}
+	s = g.resolve(s)
+	if s == nil {
+		return "{{randString 8}}"
+	}
+	if ex := example(s); ex != nil {
+		return task.StringOf(normalize(ex))
+	}
+	return g.fake(s)
+}
+
+// fake makes a template of a value of the scalar schema, which is different for each request.
+// the numbers are integers as well, a fractional value is never made, and a range without any integer, e.g. from 0.1 to 0.9, gets its minimum
+func (g *generator) fake(s *schema) string {
+	switch s.Type {
+	case "integer", "number":
+		min, max := 1, 100
+		if s.Minimum != nil {
+			min = int(math.Ceil(*s.Minimum))
+			if max < min {
+				max = min + 100
+			}
+		}
+		if s.Maximum != nil {
+			max = int(math.Floor(*s.Maximum))
+			if s.Minimum == nil && max < min {
+				min = max - 100
+			}
+		}
+		// there is no integer between the bounds
+		if max < min {
+			return strconv.FormatFloat(*s.Minimum, 'f', -1, 64)
+		}
+		return fmt.Sprintf("{{randInt %d %d}}", min, max)
+	case "boolean":
+		return "true"
+	}
+	switch s.Format {
+	case "uuid":
+		return "{{uuid}}"
+	case "date-time":
+		return `{{now "RFC3339"}}`
+	case "date":
+		return `{{now "DateOnly"}}`
+	case "email":
+		return "user-{{randString 8}}@example.com"
+	case "uri", "url":
+		return "https://example.com/{{randString 8}}"
+	}
+	n := 8
+	if s.MinLength > n {
+		n = s.MinLength
+	}
+	return fmt.Sprintf("{{randString %d}}", n)
+}
+
+// json makes the JSON of the schema, in which the fake numbers are templates without quotes.
+// refs are the references being made, ok is false if the schema refers to one of them again, so that the property is left out
+func (g *generator) json(s *schema, ex interface{}, refs []string) (string, bool) {
+	if ex != nil {
+		data, _ := json.Marshal(normalize(ex))
+		return string(data), true
+	}
+	if s != nil && s.Ref != "" {
+		for _, ref := range refs {
+			if ref == s.Ref {
+				return "", false
+			}
+		}
+		refs = append(refs, s.Ref)
+	}
+	s = g.resolve(s)
+	if s == nil {
+		return "null", true
+	}
+	if ex := example(s); ex != nil {
+		data, _ := json.Marshal(normalize(ex))
+		return string(data), true
+	}
+	if len(s.OneOf) > 0 {
+		return g.json(s.OneOf[0], nil, refs)
+	}
+	if len(s.AnyOf) > 0 {
+		return g.json(s.AnyOf[0], nil, refs)
+	}
+	switch {
+	case s.Type == "object" || len(s.Properties.names) > 0:
+		fields := make([]string, 0, len(s.Properties.names))
+		for _, name := range s.Properties.names {
+			// the id is usually given by the server
+			if name == "id" {
+				continue
+			}
+			if value, ok := g.json(s.Properties.schemas[name], nil, refs); ok {
+				key, _ := json.Marshal(name)
+				fields = append(fields, string(key)+": "+value)
+			}
+		}
+		return "{" + strings.Join(fields, ", ") + "}", true
+	case s.Type == "array":
+		if item, ok := g.json(s.Items, nil, refs); ok {
+			return "[" + item + "]", true
+		}
+		return "[]", true
+	case s.Type == "integer" || s.Type == "number" || s.Type == "boolean":
+		return g.fake(s), true
+	}
+	return `"` + g.fake(s) + `"`, true
+}
+
+// form makes the form of the properties of the schema
+func (g *generator) form(s *schema) string {
+	s = g.resolve(s)
+	if s == nil {
+		return ""
+	}
+	fields := make([]string, 0, len(s.Properties.names))
+	for _, name := range s.Properties.names {
+		if name == "id" {
+			continue
+		}
+		fields = append(fields, url.QueryEscape(name)+"="+escapeValue(g.value(s.Properties.schemas[name], nil, nil)))
+	}
+	return strings.Join(fields, "&")
+}
+
+// escapeValue escapes a value in the query of a url or in a form, but not the templates and the variables in it
+func escapeValue(value string) string {
+	if strings.Contains(value, "{{") || strings.Contains(value, "${") {
+		return value
+	}
+	return url.QueryEscape(value)
+}
+
+// escapePathValue escapes a value in a segment of the path, in which a space is '%20' rather than '+'
+func escapePathValue(value string) string {
+	if strings.Contains(value, "{{") || strings.Contains(value, "${") {
+		return value
+	}
+	return url.PathEscape(value)
+}
+
+// normalize turns the maps decoded by yaml into those which can be encoded by json
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalize(item)
+		}
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,187 @@
+package convert
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/rocketk/httptester/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleServerOpenAPI(t *testing.T) {
+	ast := assert.New(t)
+	data, err := os.ReadFile("../server/openapi.yaml")
+	ast.Nil(err)
+	s, err := ParseOpenAPI(data, OpenAPIOptions{})
+	ast.Nil(err)
+	ast.Equal([]string{"createUser", "listUsers", "getUser", "updateUser", "deleteUser"}, s.StepNames())
+	create := s.Steps[0]
+	ast.Equal("http://localhost:1234/users", create.URL)
+	ast.Equal([]string{"Content-Type: application/json"}, create.Headers)
+	ast.Equal(`{"name": "Jack", "age": {{randInt 1 120}}, "stature": {{randInt 50 250}}, "weight": {{randInt 1 300}}, "available": true}`, create.Body)
+	ast.Equal([]int{200}, create.Assert.StatusCodes)
+	ast.Equal([]task.Extractor{{Name: "usersId", JSON: "$.id"}}, create.Extract)
+	for _, step := range s.Steps[2:] {
+		ast.Equal("http://localhost:1234/users/${usersId}", step.URL)
+	}
+	ast.Nil(s.Validate())
+
+	s, err = ParseOpenAPI(data, OpenAPIOptions{Server: "https://staging.example.com/api/"})
+	ast.Nil(err)
+	ast.Equal("https://staging.example.com/api/users", s.Steps[0].URL)
+}
+
+const sampleOpenAPI = `
+openapi: 3.1.0
+paths:
+  /orders:
+    get:
+      tags: [orders]
+      parameters:
+        - $ref: "#/components/parameters/Page"
+        - {name: status, in: query, schema: {type: string, enum: [paid, shipped]}}
+        - {name: q, in: query, required: true, schema: {type: string}, example: "a b"}
+        - {name: X-Tenant, in: header, required: true, schema: {type: string, default: acme}}
+      responses:
+        "200": {description: ok}
+        "304": {description: not modified}
+        "4XX": {description: error}
+    post:
+      tags: [orders]
+      requestBody:
+        $ref: "#/components/requestBodies/Order"
+      responses:
+        "201": {description: created}
+  /orders/{orderId}/items:
+    parameters:
+      - {name: orderId, in: path, required: true, schema: {type: integer, minimum: 1000}}
+    put:
+      tags: [items]
+      requestBody:
+        content:
+          application/json:
+            example: {items: [{sku: A-1, count: 2}]}
+      responses:
+        default: {description: ok}
+  /login:
+    post:
+      operationId: login
+      requestBody:
+        content:
+          application/x-www-form-urlencoded:
+            schema:
+              properties:
+                user: {type: string, example: tester}
+                password: {type: string, format: password, minLength: 12}
+      responses:
+        "200": {description: ok}
+components:
+  parameters:
+    Page: {name: page, in: query, required: true, schema: {type: integer, default: 1}}
+  requestBodies:
+    Order:
+      content:
+        application/json:
+          schema:
+            allOf:
+              - $ref: "#/components/schemas/Base"
+              - properties:
+                  email: {type: string, format: email}
+                  placedAt: {type: string, format: date-time}
+                  tags: {type: array, items: {type: string, enum: [gift]}}
+                  note: {oneOf: [{type: string, example: fragile}, {type: integer}]}
+  schemas:
+    Base:
+      type: object
+      properties:
+        id: {type: string, format: uuid}
+        customer: {$ref: "#/components/schemas/Customer"}
+    Customer:
+      type: object
+      properties:
+        id: {type: integer}
+        name: {type: string}
+        referrer: {$ref: "#/components/schemas/Customer"}
+`
+
+func TestParseOpenAPI(t *testing.T) {
+	ast := assert.New(t)
+	s, err := ParseOpenAPI([]byte(sampleOpenAPI), OpenAPIOptions{Server: "http://localhost:8080"})
+	ast.Nil(err)
+	ast.Equal([]string{"POST /orders", "GET /orders", "PUT /orders/{orderId}/items", "login"}, s.StepNames())
+
+	post := s.Steps[0]
+	// the recursive referrer is left out
+	ast.Equal(`{"customer": {"name": "{{randString 8}}"}, "email": "user-{{randString 8}}@example.com", "placedAt": "{{now "RFC3339"}}", "tags": ["gift"], "note": "fragile"}`, post.Body)
+	ast.Equal([]int{201}, post.Assert.StatusCodes)
+	// the response of the POST is not described, so nothing is extracted
+	ast.Nil(post.Extract)
+
+	get := s.Steps[1]
+	ast.Equal("http://localhost:8080/orders?page=1&q=a+b", get.URL)
+	ast.Equal([]string{"X-Tenant: acme"}, get.Headers)
+	ast.Equal([]int{200, 304}, get.Assert.StatusCodes)
+
+	put := s.Steps[2]
+	ast.Equal("http://localhost:8080/orders/{{randInt 1000 1100}}/items", put.URL)
+	ast.Equal(`{"items":[{"count":2,"sku":"A-1"}]}`, put.Body)
+	ast.Nil(put.Assert.StatusCodes)
+
+	login := s.Steps[3]
+	ast.Equal([]string{"Content-Type: application/x-www-form-urlencoded"}, login.Headers)
+	ast.Equal("user=tester&password={{randString 12}}", login.Body)
+	ast.Nil(s.Validate())
+
+	s, err = ParseOpenAPI([]byte(sampleOpenAPI), OpenAPIOptions{Server: "http://localhost:8080", Tags: []string{"ITEMS"}})
+	ast.Nil(err)
+	ast.Equal([]string{"PUT /orders/{orderId}/items"}, s.StepNames())
+
+	_, err = ParseOpenAPI([]byte(sampleOpenAPI), OpenAPIOptions{})
+	ast.NotNil(err)
+	_, err = ParseOpenAPI([]byte(sampleOpenAPI), OpenAPIOptions{Server: "/api"})
+	ast.NotNil(err)
+	_, err = ParseOpenAPI([]byte(sampleOpenAPI), OpenAPIOptions{Server: "http://localhost", Tags: []string{"none"}})
+	ast.NotNil(err)
+	_, err = ParseOpenAPI([]byte(`swagger: "2.0"`), OpenAPIOptions{Server: "http://localhost"})
+	ast.NotNil(err)
+	_, err = ParseOpenAPI([]byte(`{openapi: 3.0.0, paths: {/a: {get: {parameters: [{$ref: "#/components/parameters/None"}]}}}}`), OpenAPIOptions{Server: "http://localhost"})
+	ast.NotNil(err)
+}
+
+func TestOpenAPIValues(t *testing.T) {
+	ast := assert.New(t)
+	s, err := ParseOpenAPI([]byte(`
+openapi: 3.0.0
+paths:
+  /tags/{name}:
+    get:
+      parameters:
+        - {name: name, in: path, required: true, schema: {type: string}, example: "a b/c"}
+        - {name: q, in: query, required: true, schema: {type: string}, example: "a b"}
+        - {name: ratio, in: query, required: true, schema: {type: number, minimum: 0.5, maximum: 2.5}}
+        - {name: share, in: query, required: true, schema: {type: number, minimum: 0.1, maximum: 0.9}}
+        - {name: delta, in: query, required: true, schema: {type: number, maximum: -0.5}}
+`), OpenAPIOptions{Server: "http://localhost"})
+	ast.Nil(err)
+	// a space is escaped as '%20' in the path, and as '+' in the query
+	ast.Equal("http://localhost/tags/a%20b%2Fc?q=a+b&ratio={{randInt 1 2}}&share=0.1&delta={{randInt -101 -1}}", s.Steps[0].URL)
+}
+
+func TestLoadOpenAPIFromURL(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/docs/openapi.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("openapi: 3.0.0\nservers: [{url: /api/v1}]\npaths: {/users: {get: {responses: {'200': {description: ok}}}}}\n"))
+	}))
+	defer server.Close()
+	s, err := LoadOpenAPI(server.URL+"/docs/openapi.yaml", OpenAPIOptions{})
+	ast.Nil(err)
+	ast.Equal(server.URL+"/api/v1/users", s.Steps[0].URL)
+	_, err = LoadOpenAPI(server.URL+"/none.yaml", OpenAPIOptions{})
+	ast.NotNil(err)
+}
@@ -0,0 +1,708 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rocketk/httptester/task"
+)
+
+// PostmanOptions are the options of converting a Postman collection
+type PostmanOptions struct {
+	// Environment is the values of the variables, e.g. loaded by LoadPostmanEnvironment, they override the variables of the collection
+	Environment map[string]string
+	// Folders keeps the requests in these folders only, all of the requests are kept if it is empty
+	Folders []string
+}
+
+type postmanCollection struct {
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	} `json:"info"`
+	Item     []*postmanItem    `json:"item"`
+	Variable []postmanVariable `json:"variable"`
+	Auth     *postmanAuth      `json:"auth"`
+	Event    []postmanEvent    `json:"event"`
+}
+
+// postmanItem is either a folder with items or a request
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []*postmanItem  `json:"item"`
+	Request *postmanRequest `json:"request"`
+	// Auth is the auth of a folder, that of a request is in the request
+	Auth  *postmanAuth   `json:"auth"`
+	Event []postmanEvent `json:"event"`
+}
+
+type postmanRequest struct {
+	Method string            `json:"method"`
+	URL    postmanURL        `json:"url"`
+	Header []postmanVariable `json:"header"`
+	Body   *postmanBody      `json:"body"`
+	Auth   *postmanAuth      `json:"auth"`
+}
+
+// UnmarshalJSON accepts the short form of a request, which is its url
+func (r *postmanRequest) UnmarshalJSON(data []byte) error {
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		*r = postmanRequest{URL: postmanURL{Raw: raw}}
+		return nil
+	}
+	type plain postmanRequest
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+type postmanURL struct {
+	Raw      string            `json:"raw"`
+	Protocol string            `json:"protocol"`
+	Host     postmanStrings    `json:"host"`
+	Port     string            `json:"port"`
+	Path     postmanStrings    `json:"path"`
+	Query    []postmanVariable `json:"query"`
+	// Variable is the values of the path variables like ':id'
+	Variable []postmanVariable `json:"variable"`
+}
+
+// UnmarshalJSON accepts the url as a string
+func (u *postmanURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		*u = postmanURL{Raw: raw}
+		return nil
+	}
+	type plain postmanURL
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+// String returns the raw url, or builds it from its parts
+func (u postmanURL) String() string {
+	if u.Raw != "" {
+		return u.Raw
+	}
+	var b strings.Builder
+	if u.Protocol != "" {
+		b.WriteString(u.Protocol + "://")
+	}
+	b.WriteString(strings.Join(u.Host, "."))
+	if u.Port != "" {
+		b.WriteString(":" + u.Port)
+	}
+	if len(u.Path) > 0 {
+		b.WriteString("/" + strings.Join(u.Path, "/"))
+	}
+	sep := "?"
+	for _, q := range u.Query {
+		if q.Disabled {
+			continue
+		}
+		b.WriteString(sep + q.Key + "=" + string(q.Value))
+		sep = "&"
+	}
+	return b.String()
+}
+
+// postmanStrings is a list of strings which may be written as a single string, like the host and the path of a url and the lines of a script
+type postmanStrings []string
+
+func (s *postmanStrings) UnmarshalJSON(data []byte) error {
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		*s = postmanStrings{strings.Trim(raw, "/")}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(s))
+}
+
+// postmanVariable is a key and a value, used by the variables, the headers, the query and the form fields
+type postmanVariable struct {
+	Key      string       `json:"key"`
+	Value    postmanValue `json:"value"`
+	Disabled bool         `json:"disabled"`
+	// Enabled is used by the environments instead of Disabled
+	Enabled *bool  `json:"enabled"`
+	Type    string `json:"type"`
+}
+
+// postmanValue is a value which may be a number or a boolean instead of a string
+type postmanValue string
+
+func (v *postmanValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*v = postmanValue(s)
+		return nil
+	}
+	if string(data) == "null" {
+		*v = ""
+		return nil
+	}
+	*v = postmanValue(data)
+	return nil
+}
+
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw"`
+	URLEncoded []postmanVariable `json:"urlencoded"`
+	FormData   []postmanVariable `json:"formdata"`
+	GraphQL    *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+	Options struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+	Disabled bool `json:"disabled"`
+}
+
+type postmanAuth struct {
+	Type   string            `json:"type"`
+	Basic  []postmanVariable `json:"basic"`
+	Bearer []postmanVariable `json:"bearer"`
+	APIKey []postmanVariable `json:"apikey"`
+	OAuth2 []postmanVariable `json:"oauth2"`
+}
+
+type postmanEvent struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec postmanStrings `json:"exec"`
+	} `json:"script"`
+}
+
+type postmanEnvironment struct {
+	Values []postmanVariable `json:"values"`
+}
+
+// postmanBoundary is the boundary of the multipart bodies, it is fixed so that the converted scenarios are stable
+const postmanBoundary = "httptester-form-boundary"
+
+var (
+	// postmanVariablePattern matches the variables of Postman like '{{baseUrl}}'
+	postmanVariablePattern = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+	// variableRefPattern matches the variables of httptester like '${token}'
+	variableRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][\w.-]*)\}`)
+	// postmanPathVariablePattern matches the path variables like ':id'
+	postmanPathVariablePattern = regexp.MustCompile(`/:([A-Za-z_][\w-]*)`)
+	// postmanSetPattern matches a line of a test script which sets a variable to a value of the response
+	postmanSetPattern = regexp.MustCompile(`(?m)(?:pm\.(?:environment|collectionVariables|variables|globals)\.set|postman\.set(?:Environment|Global)Variable)\(\s*["']([^"']+)["']\s*,\s*(.+?)\s*\)\s*;?\s*$`)
+	// postmanJSONPattern matches the variables of a test script which hold the body of the response, like 'var jsonData = pm.response.json();'
+	postmanJSONPattern = regexp.MustCompile(`(?:var|let|const)\s+(\w+)\s*=\s*(?:pm\.response\.json\(\)|JSON\.parse\(\s*responseBody\s*\))`)
+	// postmanJSONValuePattern matches a value of the body of the response, like 'pm.response.json().data.token' or 'jsonData.items[0].id'
+	postmanJSONValuePattern = regexp.MustCompile(`^(?:pm\.response\.json\(\)|JSON\.parse\(\s*responseBody\s*\)|(\w+))((?:\.\w+|\[\d+\]|\[["'][^"']+["']\])*)$`)
+	// postmanHeaderValuePattern matches a header of the response, like 'pm.response.headers.get("X-Token")'
+	postmanHeaderValuePattern = regexp.MustCompile(`^(?:pm\.response\.headers\.get|postman\.getResponseHeader)\(\s*["']([^"']+)["']\s*\)$`)
+	// postmanStatusPatterns match the tests of the status code
+	postmanStatusPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`pm\.response\.to\.have\.status\(\s*(\d{3})\s*\)`),
+		regexp.MustCompile(`pm\.expect\(\s*pm\.response\.code\s*\)\.to\.(?:eql|equal|eq|be\.equal)\(\s*(\d{3})\s*\)`),
+		regexp.MustCompile(`pm\.expect\(\s*pm\.response\.code\s*\)\.to\.be\.oneOf\(\s*\[([\d,\s]+)\]\s*\)`),
+		regexp.MustCompile(`responseCode\.code\s*===?\s*(\d{3})`),
+	}
+	// postmanDynamicVariables are the dynamic variables of Postman, which are turned into the template functions
+	postmanDynamicVariables = map[string]string{
+		"$guid":               "{{uuid}}",
+		"$randomUUID":         "{{uuid}}",
+		"$timestamp":          "{{unix}}",
+		"$isoTimestamp":       `{{now "RFC3339"}}`,
+		"$randomInt":          "{{randInt 0 1000}}",
+		"$randomAlphaNumeric": "{{randString 1}}",
+		"$randomEmail":        "user-{{randString 8}}@example.com",
+		"$randomUserName":     "user-{{randString 8}}",
+	}
+)
+
+// LoadPostman reads a Postman collection v2.1 exported in JSON
+func LoadPostman(path string, options PostmanOptions) (*task.Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePostman(data, options)
+}
+
+// LoadPostmanEnvironment reads the enabled values of a Postman environment exported in JSON
+func LoadPostmanEnvironment(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	env := &postmanEnvironment{}
+	if err := json.Unmarshal(data, env); err != nil {
+		return nil, fmt.Errorf("invalid Postman environment: %w", err)
+	}
+	values := make(map[string]string, len(env.Values))
+	for _, v := range env.Values {
+		if v.Enabled == nil || *v.Enabled {
+			values[v.Key] = string(v.Value)
+		}
+	}
+	return values, nil
+}
+
+// ParsePostman turns the requests of a Postman collection into the steps of a scenario in the order of the collection,
+// the steps are named by their folders and their names like 'Users/Create user'.
+//
+// the variables like '{{baseUrl}}' are replaced by the values of the environment or the collection, and the dynamic ones like
+// '{{$guid}}' by the template functions. a variable set to a value of the response by a test script, like
+// 'pm.environment.set("token", pm.response.json().token)', is extracted by the step and becomes '${token}' in the following steps,
+// as do the variables without a value, which are expected to be set by the data files. the auth blocks are turned into headers,
+// and the tests of the status code like 'pm.response.to.have.status(200)' into the status assertions
+func ParsePostman(data []byte, options PostmanOptions) (*task.Scenario, error) {
+	c := &postmanCollection{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid Postman collection: %w", err)
+	}
+	if c.Info.Schema != "" && !strings.Contains(c.Info.Schema, "/collection/v2") {
+		return nil, fmt.Errorf("only the Postman collections v2 are supported, but the schema is '%s'", c.Info.Schema)
+	}
+	p := &postmanConverter{
+		vars:      make(map[string]string, len(c.Variable)+len(options.Environment)),
+		extracted: make(map[string]bool, 4),
+		names:     make(stepNames, 16),
+		folders:   options.Folders,
+		scenario:  &task.Scenario{Name: c.Info.Name},
+	}
+	for _, v := range c.Variable {
+		if !v.Disabled {
+			p.vars[v.Key] = string(v.Value)
+		}
+	}
+	for k, v := range options.Environment {
+		p.vars[k] = v
+	}
+	root := &postmanItem{Item: c.Item, Auth: c.Auth, Event: c.Event}
+	if err := p.convertItems(root, nil, []*postmanItem{}, len(options.Folders) == 0); err != nil {
+		return nil, err
+	}
+	if len(p.scenario.Steps) == 0 {
+		return nil, errors.New("no requests of the collection match the folders")
+	}
+	return p.scenario, nil
+}
+
+type postmanConverter struct {
+	// vars are the static values of the variables
+	vars map[string]string
+	// extracted are the variables extracted by the converted steps
+	extracted map[string]bool
+	names     stepNames
+	folders   []string
+	scenario  *task.Scenario
+}
+
+// convertItems converts the requests in the folder in order, parents are the folders above it from the collection
+func (p *postmanConverter) convertItems(folder *postmanItem, path []string, parents []*postmanItem, kept bool) error {
+	parents = append(parents, folder)
+	for _, item := range folder.Item {
+		if item.Request == nil {
+			if err := p.convertItems(item, append(path, item.Name), parents, kept || containsFold(p.folders, item.Name)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !kept {
+			continue
+		}
+		step, err := p.step(item, path, parents)
+		if err != nil {
+			return fmt.Errorf("invalid request '%s': %w", strings.Join(append(path, item.Name), "/"), err)
+		}
+		p.scenario.Steps = append(p.scenario.Steps, step)
+	}
+	return nil
+}
+
+// step converts a request, the auth and the status tests are inherited from the nearest folder which has them
+func (p *postmanConverter) step(item *postmanItem, path []string, parents []*postmanItem) (task.Step, error) {
+	r := item.Request
+	step := task.Step{Name: p.names.unique(strings.Join(append(path, item.Name), "/")), Method: strings.ToUpper(r.Method)}
+	raw := r.URL.String()
+	if len(r.URL.Variable) > 0 {
+		values := make(map[string]string, len(r.URL.Variable))
+		for _, v := range r.URL.Variable {
+			values[v.Key] = string(v.Value)
+		}
+		raw = postmanPathVariablePattern.ReplaceAllStringFunc(raw, func(s string) string {
+			if value, ok := values[s[2:]]; ok {
+				return "/" + value
+			}
+			return s
+		})
+	}
+	var err error
+	if step.URL, err = p.replace(raw, nil); err != nil {
+		return step, err
+	}
+	if step.URL == "" {
+		return step, errors.New("the url is required")
+	}
+	if !strings.Contains(step.URL, "://") && !strings.HasPrefix(step.URL, "${") && !strings.HasPrefix(step.URL, "{{") {
+		step.URL = "http://" + step.URL
+	}
+	for _, h := range r.Header {
+		if h.Disabled {
+			continue
+		}
+		header, err := p.replace(h.Key+": "+string(h.Value), nil)
+		if err != nil {
+			return step, err
+		}
+		step.Headers = append(step.Headers, header)
+	}
+	if err := p.body(&step, r.Body); err != nil {
+		return step, err
+	}
+	auth := r.Auth
+	for i := len(parents) - 1; (auth == nil || auth.Type == "inherit") && i >= 0; i-- {
+		auth = parents[i].Auth
+	}
+	if err := p.auth(&step, auth); err != nil {
+		return step, err
+	}
+	scopes := append(append([]*postmanItem{}, parents...), item)
+	for i := len(scopes) - 1; i >= 0 && step.Assert.StatusCodes == nil; i-- {
+		step.Assert.StatusCodes = statusCodes(testScript(scopes[i].Event))
+	}
+	// only the scripts of the request itself set the variables, those of the folders would make every step extract them
+	step.Extract = extractors(testScript(item.Event))
+	for _, e := range step.Extract {
+		p.extracted[e.Name] = true
+	}
+	return step, nil
+}
+
+// replace replaces the variables of Postman in s, the escape is applied to the text and the static values, but not the
+// templates and the variables of httptester, e.g. to encode a form. the '{{' left in them is escaped from the templates as well
+func (p *postmanConverter) replace(s string, escape func(string) string) (string, error) {
+	return p.replaceDepth(s, func(s string) string {
+		if escape != nil {
+			s = escape(s)
+		}
+		return escapeTemplate(s)
+	}, 0)
+}
+
+// maxPostmanDepth stops resolving the variables whose values refer to each other
+const maxPostmanDepth = 10
+
+func (p *postmanConverter) replaceDepth(s string, escape func(string) string, depth int) (string, error) {
+	if escape == nil {
+		escape = func(s string) string { return s }
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range postmanVariablePattern.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(escape(s[last:m[0]]))
+		last = m[1]
+		name := strings.TrimSpace(s[m[2]:m[3]])
+		if strings.HasPrefix(name, "$") {
+			template, ok := postmanDynamicVariables[name]
+			if !ok {
+				return "", fmt.Errorf("the dynamic variable '%s' is not supported", name)
+			}
+			b.WriteString(template)
+			continue
+		}
+		value, ok := p.vars[name]
+		if p.extracted[name] || !ok {
+			b.WriteString("${" + name + "}")
+			continue
+		}
+		if depth >= maxPostmanDepth {
+			return "", fmt.Errorf("the variable '%s' refers to itself", name)
+		}
+		value, err := p.replaceDepth(value, escape, depth+1)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+	}
+	b.WriteString(escape(s[last:]))
+	return b.String(), nil
+}
+
+// body sets the body of the step and its content type if the request does not have one
+func (p *postmanConverter) body(step *task.Step, body *postmanBody) error {
+	if body == nil || body.Disabled {
+		return nil
+	}
+	contentType := ""
+	var err error
+	switch body.Mode {
+	case "", "none":
+		return nil
+	case "raw":
+		if step.Body, err = p.replace(body.Raw, nil); err != nil {
+			return err
+		}
+		switch body.Options.Raw.Language {
+		case "json":
+			contentType = "application/json"
+		case "xml":
+			contentType = "application/xml"
+		}
+	case "urlencoded":
+		fields := make([]string, 0, len(body.URLEncoded))
+		for _, f := range body.URLEncoded {
+			if f.Disabled {
+				continue
+			}
+			field, err := p.replace(f.Key+"\x00"+string(f.Value), url.QueryEscape)
+			if err != nil {
+				return err
+			}
+			fields = append(fields, strings.Replace(field, "%00", "=", 1))
+		}
+		step.Body = strings.Join(fields, "&")
+		contentType = "application/x-www-form-urlencoded"
+	case "formdata":
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		if err := w.SetBoundary(postmanBoundary); err != nil {
+			return err
+		}
+		for _, f := range body.FormData {
+			if f.Disabled {
+				continue
+			}
+			if f.Type == "file" {
+				return fmt.Errorf("the file field '%s' of the form is not supported", f.Key)
+			}
+			value, err := p.replace(string(f.Value), nil)
+			if err != nil {
+				return err
+			}
+			if err := w.WriteField(f.Key, value); err != nil {
+				return err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		step.Body = b.String()
+		contentType = w.FormDataContentType()
+	case "graphql":
+		if body.GraphQL == nil {
+			return nil
+		}
+		query, err := p.replace(body.GraphQL.Query, escapeJSON)
+		if err != nil {
+			return err
+		}
+		step.Body = `{"query": "` + query + `"`
+		if variables := strings.TrimSpace(body.GraphQL.Variables); variables != "" {
+			if variables, err = p.replace(variables, nil); err != nil {
+				return err
+			}
+			step.Body += `, "variables": ` + variables
+		}
+		step.Body += "}"
+		contentType = "application/json"
+	default:
+		return fmt.Errorf("the body mode '%s' is not supported", body.Mode)
+	}
+	if contentType != "" && !hasHeader(step.Headers, "Content-Type") {
+		step.Headers = append(step.Headers, "Content-Type: "+contentType)
+	}
+	return nil
+}
+
+// auth adds the header, or the query parameter, of the auth to the step
+func (p *postmanConverter) auth(step *task.Step, auth *postmanAuth) error {
+	if auth == nil {
+		return nil
+	}
+	values := func(vars []postmanVariable) (map[string]string, error) {
+		m := make(map[string]string, len(vars))
+		for _, v := range vars {
+			var value string
+			var err error
+			if auth.Type == "basic" {
+				// the credentials are encoded rather than sent as they are, so the '{{' in them is not escaped
+				if strings.Contains(string(v.Value), "{{$") {
+					return nil, fmt.Errorf("the dynamic variables are not supported in the basic auth")
+				}
+				value, err = p.replaceDepth(string(v.Value), nil, 0)
+			} else {
+				value, err = p.replace(string(v.Value), nil)
+			}
+			if err != nil {
+				return nil, err
+			}
+			m[v.Key] = value
+		}
+		return m, nil
+	}
+	var header string
+	switch auth.Type {
+	case "noauth", "inherit":
+		return nil
+	case "basic":
+		v, err := values(auth.Basic)
+		if err != nil {
+			return err
+		}
+		credentials := v["username"] + ":" + v["password"]
+		if strings.Contains(credentials, "${") {
+			// the variables are only known at run time, so the credentials are encoded by the template
+			header = "Authorization: Basic {{base64 " + printTemplate(credentials) + "}}"
+		} else {
+			header = "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+		}
+	case "bearer":
+		v, err := values(auth.Bearer)
+		if err != nil {
+			return err
+		}
+		header = "Authorization: Bearer " + v["token"]
+	case "oauth2":
+		v, err := values(auth.OAuth2)
+		if err != nil {
+			return err
+		}
+		if v["accessToken"] == "" {
+			return errors.New("the access token of the oauth2 auth is required")
+		}
+		prefix := v["headerPrefix"]
+		if prefix == "" {
+			prefix = "Bearer"
+		}
+		if v["addTokenTo"] == "queryParams" {
+			step.URL = addQuery(step.URL, "access_token", v["accessToken"])
+			return nil
+		}
+		header = "Authorization: " + prefix + " " + v["accessToken"]
+	case "apikey":
+		v, err := values(auth.APIKey)
+		if err != nil {
+			return err
+		}
+		if v["in"] == "query" {
+			step.URL = addQuery(step.URL, v["key"], v["value"])
+			return nil
+		}
+		header = v["key"] + ": " + v["value"]
+	default:
+		return fmt.Errorf("the auth type '%s' is not supported", auth.Type)
+	}
+	if !hasHeader(step.Headers, headerName(header)) {
+		step.Headers = append(step.Headers, header)
+	}
+	return nil
+}
+
+// testScript returns the test script of the events
+func testScript(events []postmanEvent) string {
+	var lines []string
+	for _, e := range events {
+		if e.Listen == "test" {
+			lines = append(lines, e.Script.Exec...)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// statusCodes returns the status codes tested by the script, nil if it does not test them
+func statusCodes(script string) []int {
+	var codes []int
+	for _, pattern := range postmanStatusPatterns {
+		for _, m := range pattern.FindAllStringSubmatch(script, -1) {
+			for _, code := range strings.Split(m[1], ",") {
+				if c, err := strconv.Atoi(strings.TrimSpace(code)); err == nil && !containsInt(codes, c) {
+					codes = append(codes, c)
+				}
+			}
+		}
+	}
+	return codes
+}
+
+// extractors returns the extractors of the variables set by the script to the values of the response,
+// the other variables set by it are ignored
+func extractors(script string) []task.Extractor {
+	jsonVars := make(map[string]bool, 1)
+	for _, m := range postmanJSONPattern.FindAllStringSubmatch(script, -1) {
+		jsonVars[m[1]] = true
+	}
+	var result []task.Extractor
+	for _, m := range postmanSetPattern.FindAllStringSubmatch(script, -1) {
+		name, value := m[1], m[2]
+		if h := postmanHeaderValuePattern.FindStringSubmatch(value); h != nil {
+			result = append(result, task.Extractor{Name: name, Header: h[1]})
+			continue
+		}
+		v := postmanJSONValuePattern.FindStringSubmatch(value)
+		if v == nil || (v[1] != "" && !jsonVars[v[1]]) {
+			continue
+		}
+		path := strings.NewReplacer(`["`, ".", `"]`, "", `['`, ".", `']`, "").Replace(v[2])
+		result = append(result, task.Extractor{Name: name, JSON: "$" + path})
+	}
+	return result
+}
+
+// printTemplate turns a value with variables like 'admin:${password}' into the template which prints it,
+// like '(print "admin:" (var "password"))', so that it can be passed to a template function
+func printTemplate(s string) string {
+	args := make([]string, 0, 4)
+	last := 0
+	for _, m := range variableRefPattern.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] > last {
+			args = append(args, strconv.Quote(s[last:m[0]]))
+		}
+		args = append(args, "(var "+strconv.Quote(s[m[2]:m[3]])+")")
+		last = m[1]
+	}
+	if last < len(s) {
+		args = append(args, strconv.Quote(s[last:]))
+	}
+	return "(print " + strings.Join(args, " ") + ")"
+}
+
+// escapeJSON escapes s in a JSON string
+func escapeJSON(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
+
+// addQuery adds a query parameter to the url
+func addQuery(u string, key string, value string) string {
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	return u + sep + escapeValue(key) + "=" + escapeValue(value)
+}
+
+func hasHeader(headers []string, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(headerName(h), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rocketk/httptester/task"
+	"github.com/stretchr/testify/assert"
+)
+
+const samplePostman = `{
+  "info": {"name": "Users", "schema": "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"},
+  "variable": [
+    {"key": "baseUrl", "value": "http://{{host}}/api"},
+    {"key": "host", "value": "localhost:1234"},
+    {"key": "token", "value": "static"},
+    {"key": "off", "value": "x", "disabled": true}
+  ],
+  "auth": {"type": "bearer", "bearer": [{"key": "token", "value": "{{token}}", "type": "string"}]},
+  "event": [{"listen": "test", "script": {"exec": ["pm.test('ok', function () {", "  pm.response.to.have.status(200);", "});"]}}],
+  "item": [
+    {
+      "name": "Auth",
+      "item": [
+        {
+          "name": "Login",
+          "event": [{"listen": "test", "script": {"exec": [
+            "var jsonData = pm.response.json();",
+            "pm.environment.set(\"token\", jsonData.data.token);",
+            "pm.collectionVariables.set('session', pm.response.headers.get('X-Session'));",
+            "pm.environment.set(\"when\", new Date());",
+            "pm.expect(pm.response.code).to.be.oneOf([200, 201]);"
+          ]}}],
+          "request": {
+            "auth": {"type": "basic", "basic": [{"key": "username", "value": "admin"}, {"key": "password", "value": "{{password}}"}]},
+            "method": "POST",
+            "url": {"raw": "{{baseUrl}}/login", "host": ["{{baseUrl}}"], "path": ["login"]},
+            "body": {"mode": "urlencoded", "urlencoded": [
+              {"key": "user", "value": "a b&c"},
+              {"key": "id", "value": "{{$guid}}"},
+              {"key": "off", "value": "1", "disabled": true}
+            ]}
+          }
+        }
+      ]
+    },
+    {
+      "name": "Users",
+      "item": [
+        {
+          "name": "Create user",
+          "request": {
+            "auth": {"type": "inherit"},
+            "method": "post",
+            "header": [
+              {"key": "X-Trace", "value": "{{$randomInt}}"},
+              {"key": "X-Off", "value": "1", "disabled": true}
+            ],
+            "url": "{{baseUrl}}/users",
+            "body": {"mode": "raw", "raw": "{\"name\": \"{{name}}\", \"token\": \"{{token}}\"}", "options": {"raw": {"language": "json"}}}
+          }
+        },
+        {
+          "name": "Get user",
+          "event": [{"listen": "test", "script": {"exec": "pm.response.to.have.status(200);"}}],
+          "request": {
+            "auth": {"type": "noauth"},
+            "method": "GET",
+            "url": {"protocol": "http", "host": ["localhost"], "port": "1234", "path": ["users", ":id"], "variable": [{"key": "id", "value": "7"}],
+              "query": [{"key": "fields", "value": "name"}, {"key": "off", "value": "1", "disabled": true}]}
+          }
+        },
+        {
+          "name": "Get user",
+          "request": {
+            "auth": {"type": "apikey", "apikey": [{"key": "key", "value": "api_key"}, {"key": "value", "value": "{{apiKey}}"}, {"key": "in", "value": "query"}]},
+            "url": "localhost:1234/users/8"
+          }
+        }
+      ]
+    }
+  ]
+}`
+
+func TestParsePostman(t *testing.T) {
+	ast := assert.New(t)
+	s, err := ParsePostman([]byte(samplePostman), PostmanOptions{Environment: map[string]string{"host": "staging:8080", "password": "secret"}})
+	ast.Nil(err)
+	ast.Equal("Users", s.Name)
+	ast.Equal([]string{"Auth/Login", "Users/Create user", "Users/Get user", "Users/Get user (2)"}, s.StepNames())
+
+	login := s.Steps[0]
+	ast.Equal("POST", login.Method)
+	// the environment overrides the collection, and the variables refer to each other
+	ast.Equal("http://staging:8080/api/login", login.URL)
+	ast.Equal("user=a+b%26c&id={{uuid}}", login.Body)
+	ast.Equal([]string{"Content-Type: application/x-www-form-urlencoded", "Authorization: Basic YWRtaW46c2VjcmV0"}, login.Headers)
+	ast.Equal([]int{200, 201}, login.Assert.StatusCodes)
+	ast.Equal([]task.Extractor{{Name: "token", JSON: "$.data.token"}, {Name: "session", Header: "X-Session"}}, login.Extract)
+
+	create := s.Steps[1]
+	ast.Equal("POST", create.Method)
+	// the token is extracted by the login, and the name is expected from the data files
+	ast.Equal(`{"name": "${name}", "token": "${token}"}`, create.Body)
+	ast.Equal([]string{"X-Trace: {{randInt 0 1000}}", "Content-Type: application/json", "Authorization: Bearer ${token}"}, create.Headers)
+	// the status test of the collection is inherited
+	ast.Equal([]int{200}, create.Assert.StatusCodes)
+	ast.Nil(create.Extract)
+
+	get := s.Steps[2]
+	ast.Equal("http://localhost:1234/users/7?fields=name", get.URL)
+	ast.Nil(get.Headers)
+	ast.Equal("http://localhost:1234/users/8?api_key=${apiKey}", s.Steps[3].URL)
+	ast.Nil(s.Steps[3].Headers)
+	ast.Nil(s.Validate())
+
+	s, err = ParsePostman([]byte(samplePostman), PostmanOptions{Folders: []string{"users"}})
+	ast.Nil(err)
+	ast.Equal([]string{"Users/Create user", "Users/Get user", "Users/Get user (2)"}, s.StepNames())
+	// the token is not extracted without the login
+	ast.Equal("Authorization: Bearer static", s.Steps[0].Headers[2])
+
+	_, err = ParsePostman([]byte(samplePostman), PostmanOptions{Folders: []string{"none"}})
+	ast.NotNil(err)
+	for _, collection := range []string{
+		`{"info": {"schema": "https://schema.getpostman.com/json/collection/v1.0.0/collection.json"}, "requests": []}`,
+		`{"item": [{"name": "a", "request": {"url": "http://localhost/{{$randomCity}}"}}]}`,
+		`{"item": [{"name": "a", "request": {"url": "http://localhost/", "auth": {"type": "digest"}}}]}`,
+		`{"item": [{"name": "a", "request": {"url": "http://localhost/", "body": {"mode": "formdata", "formdata": [{"key": "f", "type": "file", "src": "a.txt"}]}}}]}`,
+		`{"variable": [{"key": "a", "value": "{{b}}"}, {"key": "b", "value": "{{a}}"}], "item": [{"name": "a", "request": {"url": "http://localhost/{{a}}"}}]}`,
+	} {
+		_, err = ParsePostman([]byte(collection), PostmanOptions{})
+		ast.NotNil(err, collection)
+	}
+}
+
+func TestParsePostmanBasicAuth(t *testing.T) {
+	ast := assert.New(t)
+	s, err := ParsePostman([]byte(`{"item": [{"name": "login", "request": {"url": "http://localhost/login",
+  "auth": {"type": "basic", "basic": [{"key": "username", "value": "admin"}, {"key": "password", "value": "{{password}}"}]}}}]}`), PostmanOptions{})
+	ast.Nil(err)
+	// the password is expected from the data files, so it is encoded at run time
+	ast.Equal([]string{`Authorization: Basic {{base64 (print "admin:" (var "password"))}}`}, s.Steps[0].Headers)
+	ast.Nil(task.ValidateTemplate(s.Steps[0].Headers[0]))
+
+	_, err = ParsePostman([]byte(`{"item": [{"name": "login", "request": {"url": "http://localhost/login",
+  "auth": {"type": "basic", "basic": [{"key": "username", "value": "{{$guid}}"}]}}}]}`), PostmanOptions{})
+	ast.NotNil(err)
+}
+
+func TestParsePostmanWithBraces(t *testing.T) {
+	ast := assert.New(t)
+	s, err := ParsePostman([]byte(`{"variable": [{"key": "tpl", "value": "a{{b"}], "item": [{"name": "a", "request": {"method": "POST", "url": "http://localhost/",
+  "auth": {"type": "basic", "basic": [{"key": "username", "value": "{{{{name}}"}, {"key": "password", "value": "x"}]},
+  "body": {"mode": "raw", "raw": "{{tpl}} {{name}} {{$guid}} {{ {{"}}}]}`), PostmanOptions{})
+	ast.Nil(err)
+	// only the variables are converted, the rest of '{{' is sent as it is
+	ast.Equal(`a{{"{{"}}b ${name} {{uuid}} {{"{{"}} {{"{{"}}`, s.Steps[0].Body)
+	ast.Equal([]string{`Authorization: Basic {{base64 (print "{{" (var "name") ":x")}}`}, s.Steps[0].Headers)
+	ast.Nil(s.Validate())
+}
+
+func TestParsePostmanBodies(t *testing.T) {
+	ast := assert.New(t)
+	s, err := ParsePostman([]byte(`{"variable": [{"key": "name", "value": "a \"b\""}], "item": [
+  {"name": "form", "request": {"method": "POST", "url": "http://localhost/form", "body": {"mode": "formdata", "formdata": [{"key": "name", "value": "{{name}}", "type": "text"}]}}},
+  {"name": "graphql", "request": {"method": "POST", "url": "http://localhost/graphql", "header": [{"key": "content-type", "value": "application/graphql+json"}],
+    "body": {"mode": "graphql", "graphql": {"query": "query { user(name: \"{{name}}\") { id } }", "variables": "{\"id\": {{id}}}"}}}}
+]}`), PostmanOptions{})
+	ast.Nil(err)
+	ast.Equal([]string{"Content-Type: multipart/form-data; boundary=httptester-form-boundary"}, s.Steps[0].Headers)
+	ast.Equal("--httptester-form-boundary\r\nContent-Disposition: form-data; name=\"name\"\r\n\r\na \"b\"\r\n--httptester-form-boundary--\r\n", s.Steps[0].Body)
+	ast.Equal([]string{"content-type: application/graphql+json"}, s.Steps[1].Headers)
+	ast.Equal(`{"query": "query { user(name: \"a \"b\"\") { id } }", "variables": {"id": ${id}}}`, s.Steps[1].Body)
+}
+
+func TestLoadPostmanEnvironment(t *testing.T) {
+	ast := assert.New(t)
+	path := filepath.Join(t.TempDir(), "env.json")
+	ast.Nil(os.WriteFile(path, []byte(`{"name": "staging", "values": [
+  {"key": "host", "value": "staging:8080", "enabled": true},
+  {"key": "port", "value": 8080},
+  {"key": "off", "value": "1", "enabled": false}
+]}`), 0644))
+	env, err := LoadPostmanEnvironment(path)
+	ast.Nil(err)
+	ast.Equal(map[string]string{"host": "staging:8080", "port": "8080"}, env)
+}
@@ -14,6 +14,11 @@ func (s *SampleServer) Root(c *gin.Context) {
 	})
 }
 
+// OpenAPI returns the specification of the api, which can be turned into a scenario by 'httptester openapi gen'
+func (s *SampleServer) OpenAPI(c *gin.Context) {
+	c.Data(200, "application/yaml", openAPISpec)
+}
+
 func (s *SampleServer) List(c *gin.Context) {
 
 	c.JSON(200, s.Data.GetAllOrdered())
@@ -24,6 +24,9 @@ type User struct {
 //go:embed sample_data.json
 var sampleData []byte
 
+//go:embed openapi.yaml
+var openAPISpec []byte
+
 func (s *SampleServer) InitData() {
 	var users []User
 	err := json.Unmarshal(sampleData, &users)
@@ -46,6 +49,7 @@ func (s *SampleServer) Serve() {
 	r.DELETE("/users/:id", s.Delete)
 	r.PUT("/users/:id", s.Put)
 	r.POST("/users", s.Post)
+	r.GET("/openapi.yaml", s.OpenAPI)
 	fmt.Printf("sample restful-api server started successfully! try it out: \ncurl http:127.0.0.1:%d\n", s.Port)
 
 	// fmt.Printf("listening on %d\n", s.Port)
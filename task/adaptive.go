@@ -0,0 +1,241 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	AIMD string = "aimd"
+	PID  string = "pid"
+)
+
+// AdaptiveDef makes the plan change the number of workers during the run, so that the latency stays near the target
+type AdaptiveDef struct {
+	// Controller is either AIMD or PID
+	Controller    string
+	TargetLatency time.Duration
+	// Percentile of the latency to track, e.g. 95
+	Percentile     float64
+	MaxConcurrency int
+	// Window is how often the latency is measured and the number of workers is adjusted
+	Window time.Duration
+}
+
+func (a AdaptiveDef) Enabled() bool {
+	return a.TargetLatency > 0
+}
+
+func (a AdaptiveDef) Validate() error {
+	if a.Controller != AIMD && a.Controller != PID {
+		return fmt.Errorf("unknown controller '%s', it should be either '%s' or '%s'", a.Controller, AIMD, PID)
+	}
+	if a.Percentile <= 0 || a.Percentile > 100 {
+		return fmt.Errorf("the percentile must be in (0, 100]")
+	}
+	if a.Window <= 0 {
+		return fmt.Errorf("the window must be positive")
+	}
+	if a.MaxConcurrency < 1 {
+		return fmt.Errorf("the max concurrency must be positive")
+	}
+	return nil
+}
+
+// LatencyWindow is the latency observed within a window of the run
+type LatencyWindow struct {
+	Start time.Time
+	End   time.Time
+	Count int
+	// Latency at the tracked percentile
+	Latency time.Duration
+	// Stalled is true if no request finished within the window
+	Stalled bool
+}
+
+// ConcurrencyController decides the number of workers for the next window
+type ConcurrencyController interface {
+	Next(current int, window LatencyWindow) int
+}
+
+// AIMDController adds Increase workers while the latency is under the target, and multiplies the workers by DecreaseFactor otherwise
+type AIMDController struct {
+	Target         time.Duration
+	Increase       int
+	DecreaseFactor float64
+}
+
+func (c *AIMDController) Next(current int, window LatencyWindow) int {
+	if !window.Stalled && window.Latency <= c.Target {
+		return current + c.Increase
+	}
+	return int(float64(current) * c.DecreaseFactor)
+}
+
+// PIDController adjusts the workers in proportion to the relative error of the latency, its integral and its derivative
+type PIDController struct {
+	Target     time.Duration
+	Kp, Ki, Kd float64
+	integral   float64
+	lastError  float64
+}
+
+func (c *PIDController) Next(current int, window LatencyWindow) int {
+	e := -1.0
+	if !window.Stalled {
+		e = float64(c.Target-window.Latency) / float64(c.Target)
+		e = math.Max(-1, math.Min(1, e))
+	}
+	c.integral = math.Max(-2, math.Min(2, c.integral+e))
+	derivative := e - c.lastError
+	c.lastError = e
+	delta := c.Kp*e + c.Ki*c.integral + c.Kd*derivative
+	step := int(math.Round(float64(current) * delta))
+	// a few workers would never change by rounding, so a clear error moves at least 1 worker
+	if step == 0 && math.Abs(delta) >= 0.05 {
+		step = int(math.Copysign(1, delta))
+	}
+	return current + step
+}
+
+func newController(def AdaptiveDef) ConcurrencyController {
+	if def.Controller == PID {
+		return &PIDController{Target: def.TargetLatency, Kp: 0.5, Ki: 0.1, Kd: 0.1}
+	}
+	return &AIMDController{Target: def.TargetLatency, Increase: 1, DecreaseFactor: 0.75}
+}
+
+// WindowListener passes every summary to the wrapped listener, and sends the latency of each window to the windows channel
+type WindowListener struct {
+	Listener
+	window     time.Duration
+	percentile float64
+	windows    chan LatencyWindow
+	start      time.Time
+	costs      []int64
+}
+
+func BuildWindowListener(listener Listener, window time.Duration, percentile float64, windows chan LatencyWindow) WindowListener {
+	return WindowListener{
+		Listener:   listener,
+		window:     window,
+		percentile: percentile,
+		windows:    windows,
+		costs:      make([]int64, 0, 1024),
+	}
+}
+
+func (l *WindowListener) OnStart() {
+	l.Listener.OnStart()
+	l.start = time.Now()
+}
+
+func (l *WindowListener) OnRequestFinished(summary Summary) {
+	l.Listener.OnRequestFinished(summary)
+	if summary.Dropped {
+		return
+	}
+	if summary.EndTime.Sub(l.start) >= l.window {
+		l.flush(summary.EndTime)
+	}
+	l.costs = append(l.costs, summary.Latency().Nanoseconds())
+}
+
+// flush sends the window, it is dropped if the scheduler is not keeping up
+func (l *WindowListener) flush(end time.Time) {
+	sort.Slice(l.costs, func(i, j int) bool { return l.costs[i] < l.costs[j] })
+	w := LatencyWindow{
+		Start:   l.start,
+		End:     end,
+		Count:   len(l.costs),
+		Latency: time.Duration(percentileOf(l.costs, l.percentile)),
+	}
+	select {
+	case l.windows <- w:
+	default:
+	}
+	l.start = end
+	l.costs = l.costs[:0]
+}
+
+// AdaptiveHistory records the number of workers over the run
+type AdaptiveHistory struct {
+	def     AdaptiveDef
+	samples []int
+	latest  int
+}
+
+func (h *AdaptiveHistory) PrintToStdOut() {
+	fmt.Println("-- Adaptive Concurrency --")
+	fmt.Printf("controller: %s\n", h.def.Controller)
+	fmt.Printf("target: p%g <= %s\n", h.def.Percentile, h.def.TargetLatency)
+	if len(h.samples) == 0 {
+		fmt.Printf("final concurrency: %d\n", h.latest)
+		return
+	}
+	min, max, total := h.samples[0], h.samples[0], 0
+	for _, n := range h.samples {
+		if n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+		total += n
+	}
+	fmt.Printf("final concurrency: %d\n", h.latest)
+	fmt.Printf("min concurrency: %d\n", min)
+	fmt.Printf("max concurrency: %d\n", max)
+	fmt.Printf("mean concurrency: %.1f\n", float64(total)/float64(len(h.samples)))
+}
+
+// startAdaptive starts Concurrency workers and then adjusts them by the controller after each latency window
+func (p *Plan) startAdaptive(ctx context.Context, wg *sync.WaitGroup, summaryChannel chan Summary) {
+	def := p.TaskDef.Adaptive
+	pool := &workerPool{
+		plan:           p,
+		ctx:            ctx,
+		wg:             wg,
+		summaryChannel: summaryChannel,
+	}
+	pool.scale(p.TaskDef.Concurrency)
+	p.adaptiveHistory = &AdaptiveHistory{def: def, latest: pool.size()}
+	controller := newController(def)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// a window is only closed by a finished request, so the lack of windows means the requests are stalled
+		stall := time.NewTicker(2 * def.Window)
+		defer stall.Stop()
+		lastWindow := time.Now()
+		for {
+			var window LatencyWindow
+			select {
+			case <-ctx.Done():
+				return
+			case window = <-p.windows:
+				lastWindow = time.Now()
+			case now := <-stall.C:
+				if now.Sub(lastWindow) < 2*def.Window {
+					continue
+				}
+				window = LatencyWindow{Start: lastWindow, End: now, Stalled: true}
+				lastWindow = now
+			}
+			n := controller.Next(pool.size(), window)
+			if n < 1 {
+				n = 1
+			}
+			if n > def.MaxConcurrency {
+				n = def.MaxConcurrency
+			}
+			pool.scale(n)
+			p.adaptiveHistory.samples = append(p.adaptiveHistory.samples, n)
+			p.adaptiveHistory.latest = n
+		}
+	}()
+}
@@ -0,0 +1,50 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIMDController(t *testing.T) {
+	ast := assert.New(t)
+	c := &AIMDController{Target: 100 * time.Millisecond, Increase: 1, DecreaseFactor: 0.75}
+	ast.Equal(11, c.Next(10, LatencyWindow{Latency: 50 * time.Millisecond}))
+	ast.Equal(11, c.Next(10, LatencyWindow{Latency: 100 * time.Millisecond}))
+	ast.Equal(75, c.Next(100, LatencyWindow{Latency: 150 * time.Millisecond}))
+	ast.Equal(75, c.Next(100, LatencyWindow{Stalled: true}))
+}
+
+func TestPIDController(t *testing.T) {
+	ast := assert.New(t)
+	c := &PIDController{Target: 100 * time.Millisecond, Kp: 0.5, Ki: 0.1, Kd: 0.1}
+	ast.Greater(c.Next(10, LatencyWindow{Latency: 50 * time.Millisecond}), 10)
+	c = &PIDController{Target: 100 * time.Millisecond, Kp: 0.5, Ki: 0.1, Kd: 0.1}
+	ast.Less(c.Next(10, LatencyWindow{Latency: 200 * time.Millisecond}), 10)
+	c = &PIDController{Target: 100 * time.Millisecond, Kp: 0.5, Ki: 0.1, Kd: 0.1}
+	ast.Equal(2, c.Next(1, LatencyWindow{Latency: 10 * time.Millisecond}))
+}
+
+func TestWindowListener(t *testing.T) {
+	ast := assert.New(t)
+	simple := BuildSimpleListener(0, "ms")
+	windows := make(chan LatencyWindow, 4)
+	l := BuildWindowListener(&simple, time.Second, 50, windows)
+	l.OnStart()
+	start := l.start
+	for i := 1; i <= 4; i++ {
+		l.OnRequestFinished(Summary{
+			StartTime: start,
+			EndTime:   start.Add(time.Duration(i) * 100 * time.Millisecond),
+			Success:   true,
+		})
+	}
+	ast.Len(windows, 0)
+	l.OnRequestFinished(Summary{StartTime: start, EndTime: start.Add(1500 * time.Millisecond), Success: true})
+	ast.Len(windows, 1)
+	w := <-windows
+	ast.Equal(4, w.Count)
+	ast.Equal(200*time.Millisecond, w.Latency)
+	ast.Equal(5, simple.TotalCount())
+}
@@ -108,6 +108,13 @@ func (a *JsonPathAssertion) Validate() error {
 		return errors.New("Expression is required")
 	}
 	args := strings.Split(a.Expression, " ")
+	// 'path exists' asserts that the path is found in the body
+	if len(args) == 2 && args[1] == "exists" {
+		a.query = args[0]
+		a.handler = exists
+		a.initialized = true
+		return nil
+	}
 	if len(args) < 3 {
 		return errors.New("Invalid Expression")
 	}
@@ -124,6 +131,10 @@ func (a *JsonPathAssertion) Validate() error {
 	return nil
 }
 
+func exists(arg interface{}, target string) (bool, string) {
+	return arg != nil, "Assertion failed: the path is not found"
+}
+
 func gt(arg interface{}, target string) (bool, string) {
 	switch arg.(type) {
 	case int:
@@ -107,6 +107,20 @@ func TestJsonPathAssertionSuccessForNumber(t *testing.T) {
 	ast.True(success)
 }
 
+func TestJsonPathAssertionExists(t *testing.T) {
+	ast := assert.New(t)
+	resp := HttpResponse{StatusCode: 200, Body: responseJsonBodyBytes}
+	ass := JsonPathAssertion{Expression: "$.store.bicycle.color exists"}
+	success, _ := ass.Assert(resp)
+	ast.True(success)
+	ass = JsonPathAssertion{Expression: "$.store.car exists"}
+	success, _ = ass.Assert(resp)
+	ast.False(success)
+	// a path alone is still invalid
+	ass = JsonPathAssertion{Expression: "$.store.bicycle.color"}
+	ast.NotNil(ass.Validate())
+}
+
 func TestUUID(t *testing.T) {
 	log.Println(uuid.NewUUID())
 }
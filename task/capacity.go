@@ -0,0 +1,215 @@
+package task
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Criterion is a limit the result of a run must meet, e.g. 'p99<300ms' or 'error-rate<1%'
+type Criterion struct {
+	// Metric is one of 'p50', 'p99.9' and so on, 'mean', 'max', 'error-rate' or 'throughput'
+	Metric    string
+	Operator  string
+	Threshold float64
+	raw       string
+}
+
+var criterionOperators = []string{"<=", ">=", "<", ">"}
+
+// ParseCriterion parses a criterion like 'p99<300ms', 'mean<=100ms', 'error-rate<1%' or 'throughput>=1000'
+func ParseCriterion(expression string) (Criterion, error) {
+	expression = strings.TrimSpace(expression)
+	for _, op := range criterionOperators {
+		metric, value, found := strings.Cut(expression, op)
+		if !found {
+			continue
+		}
+		c := Criterion{
+			Metric:   strings.ToLower(strings.TrimSpace(metric)),
+			Operator: op,
+			raw:      expression,
+		}
+		value = strings.TrimSpace(value)
+		var err error
+		switch {
+		case c.Metric == "error-rate":
+			if strings.HasSuffix(value, "%") {
+				c.Threshold, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+				c.Threshold /= 100
+			} else {
+				c.Threshold, err = strconv.ParseFloat(value, 64)
+			}
+		case c.Metric == "throughput":
+			c.Threshold, err = strconv.ParseFloat(value, 64)
+		case c.Metric == "mean" || c.Metric == "max" || isPercentileMetric(c.Metric):
+			var d time.Duration
+			d, err = time.ParseDuration(value)
+			c.Threshold = float64(d)
+		default:
+			return c, fmt.Errorf("unknown metric '%s' in criterion '%s'", metric, expression)
+		}
+		if err != nil {
+			return c, fmt.Errorf("invalid value in criterion '%s': %w", expression, err)
+		}
+		return c, nil
+	}
+	return Criterion{}, fmt.Errorf("invalid criterion '%s', it should be like 'p99<300ms' or 'error-rate<1%%'", expression)
+}
+
+func isPercentileMetric(metric string) bool {
+	if !strings.HasPrefix(metric, "p") {
+		return false
+	}
+	p, err := strconv.ParseFloat(metric[1:], 64)
+	return err == nil && p > 0 && p <= 100
+}
+
+// Observe returns the value of the metric in the result, latencies are in nanoseconds
+func (c Criterion) Observe(result *SimpleListener) float64 {
+	switch c.Metric {
+	case "error-rate":
+		return result.ErrorRate()
+	case "throughput":
+		return float64(result.Throughput())
+	case "mean":
+		return float64(result.Mean())
+	case "max":
+		return float64(result.Max())
+	}
+	p, _ := strconv.ParseFloat(c.Metric[1:], 64)
+	return float64(result.Percentile(p))
+}
+
+// Met reports whether the observed value meets the criterion
+func (c Criterion) Met(observed float64) bool {
+	switch c.Operator {
+	case "<":
+		return observed < c.Threshold
+	case "<=":
+		return observed <= c.Threshold
+	case ">":
+		return observed > c.Threshold
+	default:
+		return observed >= c.Threshold
+	}
+}
+
+// Format renders an observed value of the metric
+func (c Criterion) Format(observed float64) string {
+	switch c.Metric {
+	case "error-rate":
+		return fmt.Sprintf("%.2f%%", observed*100)
+	case "throughput":
+		return fmt.Sprintf("%.0f/s", observed)
+	}
+	return time.Duration(observed).String()
+}
+
+func (c Criterion) String() string {
+	return c.raw
+}
+
+// CapacityTrial is the result of a run at a single load
+type CapacityTrial struct {
+	Load     float64
+	Result   *SimpleListener
+	Observed []float64
+	Passed   bool
+}
+
+// CapacitySearch steps the load from Start by Step until a criterion is breached or Max is reached,
+// and then narrows in with a binary search until the interval is not larger than Precision
+type CapacitySearch struct {
+	Start     float64
+	Step      float64
+	Max       float64
+	Precision float64
+	Criteria  []Criterion
+	// Run runs a plan at the load and returns its result
+	Run func(load float64) *SimpleListener
+	// OnTrial is called after each run if it is not nil
+	OnTrial func(trial CapacityTrial)
+	Trials  []CapacityTrial
+}
+
+func (c *CapacitySearch) Validate() error {
+	if c.Start <= 0 || c.Step <= 0 {
+		return fmt.Errorf("the start and the step must be positive")
+	}
+	if c.Max < c.Start {
+		return fmt.Errorf("the max must not be less than the start")
+	}
+	if len(c.Criteria) == 0 {
+		return fmt.Errorf("at least 1 criterion is required")
+	}
+	return nil
+}
+
+// Find returns the highest load that met all the criteria, found is false if even the start load breached them
+func (c *CapacitySearch) Find() (best float64, found bool) {
+	precision := c.Precision
+	if precision <= 0 {
+		precision = 1
+	}
+	var breached float64
+	load := c.Start
+	for {
+		if !c.try(load) {
+			breached = load
+			break
+		}
+		best, found = load, true
+		if load >= c.Max {
+			return best, found
+		}
+		load = math.Min(load+c.Step, c.Max)
+	}
+	low := best
+	if !found {
+		low = 0
+	}
+	for breached-low > precision {
+		mid := c.round(low + (breached-low)/2)
+		if mid <= low || mid >= breached {
+			break
+		}
+		if c.try(mid) {
+			low, best, found = mid, mid, true
+		} else {
+			breached = mid
+		}
+	}
+	return best, found
+}
+
+// round keeps the load an integer if the search started with integers, since concurrency can not be fractional
+func (c *CapacitySearch) round(load float64) float64 {
+	if c.Start == math.Trunc(c.Start) && c.Step == math.Trunc(c.Step) {
+		return math.Floor(load)
+	}
+	return load
+}
+
+func (c *CapacitySearch) try(load float64) bool {
+	result := c.Run(load)
+	trial := CapacityTrial{
+		Load:     load,
+		Result:   result,
+		Observed: make([]float64, len(c.Criteria)),
+		Passed:   result.TotalCount() > 0,
+	}
+	for i, criterion := range c.Criteria {
+		trial.Observed[i] = criterion.Observe(result)
+		if !criterion.Met(trial.Observed[i]) {
+			trial.Passed = false
+		}
+	}
+	c.Trials = append(c.Trials, trial)
+	if c.OnTrial != nil {
+		c.OnTrial(trial)
+	}
+	return trial.Passed
+}
@@ -0,0 +1,95 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCriterion(t *testing.T) {
+	ast := assert.New(t)
+	c, err := ParseCriterion("p99<300ms")
+	ast.Nil(err)
+	ast.Equal("p99", c.Metric)
+	ast.Equal("<", c.Operator)
+	ast.Equal(float64(300*time.Millisecond), c.Threshold)
+	c, err = ParseCriterion("error-rate <= 1%")
+	ast.Nil(err)
+	ast.Equal("<=", c.Operator)
+	ast.InDelta(0.01, c.Threshold, 1e-9)
+	c, err = ParseCriterion("p99.9<1s")
+	ast.Nil(err)
+	ast.Equal("p99.9", c.Metric)
+	_, err = ParseCriterion("p99<300")
+	ast.NotNil(err)
+	_, err = ParseCriterion("latency<300ms")
+	ast.NotNil(err)
+	_, err = ParseCriterion("p99")
+	ast.NotNil(err)
+}
+
+// fakeResult returns a result whose every request took the latency in milliseconds
+func fakeResult(latency int64) *SimpleListener {
+	l := BuildSimpleListener(10, "ms")
+	l.successCount = 10
+	for i := 0; i < 10; i++ {
+		l.successLatency.Record(latency * int64(time.Millisecond))
+	}
+	l.calculate()
+	return &l
+}
+
+func TestCapacitySearch(t *testing.T) {
+	ast := assert.New(t)
+	criterion, _ := ParseCriterion("p99<300ms")
+	search := &CapacitySearch{
+		Start:    10,
+		Step:     10,
+		Max:      100,
+		Criteria: []Criterion{criterion},
+		Run: func(load float64) *SimpleListener {
+			// the latency grows with the load, 37 is the last load under 300ms
+			return fakeResult(int64(load * 8))
+		},
+	}
+	ast.Nil(search.Validate())
+	best, found := search.Find()
+	ast.True(found)
+	ast.Equal(float64(37), best)
+	loads := make([]float64, 0, len(search.Trials))
+	for _, trial := range search.Trials {
+		loads = append(loads, trial.Load)
+	}
+	ast.Equal([]float64{10, 20, 30, 40, 35, 37, 38}, loads)
+}
+
+func TestCapacitySearchReachesMax(t *testing.T) {
+	ast := assert.New(t)
+	criterion, _ := ParseCriterion("p99<300ms")
+	search := &CapacitySearch{
+		Start:    10,
+		Step:     10,
+		Max:      25,
+		Criteria: []Criterion{criterion},
+		Run:      func(load float64) *SimpleListener { return fakeResult(1) },
+	}
+	best, found := search.Find()
+	ast.True(found)
+	ast.Equal(float64(25), best)
+}
+
+func TestCapacitySearchNotFound(t *testing.T) {
+	ast := assert.New(t)
+	criterion, _ := ParseCriterion("p99<300ms")
+	search := &CapacitySearch{
+		Start:     10,
+		Step:      10,
+		Max:       100,
+		Criteria:  []Criterion{criterion},
+		Run:       func(load float64) *SimpleListener { return fakeResult(500) },
+		Precision: 1,
+	}
+	_, found := search.Find()
+	ast.False(found)
+}
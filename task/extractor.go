@@ -0,0 +1,133 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/oliveagle/jsonpath"
+)
+
+// Extractor captures a value of the response into the variable Name of the virtual user, from exactly one of the sources:
+// JSON is a jsonpath into the body, Regex is matched against the body and its first capture group is taken if it has one,
+// Header and Cookie are the names of a response header and a cookie set by the response
+type Extractor struct {
+	Name   string `yaml:"name"`
+	JSON   string `yaml:"json,omitempty"`
+	Regex  string `yaml:"regex,omitempty"`
+	Header string `yaml:"header,omitempty"`
+	Cookie string `yaml:"cookie,omitempty"`
+	regex  *regexp.Regexp
+}
+
+func (e *Extractor) Validate() error {
+	if e.Name == "" {
+		return errors.New("the name of the extractor is required")
+	}
+	sources := 0
+	for _, source := range []string{e.JSON, e.Regex, e.Header, e.Cookie} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("the extractor '%s' must have exactly 1 of json, regex, header and cookie", e.Name)
+	}
+	if e.Regex != "" {
+		r, err := regexp.Compile(e.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex of the extractor '%s': %w", e.Name, err)
+		}
+		e.regex = r
+	}
+	return nil
+}
+
+// Extract returns the value of the response, ok is false if it is not found
+func (e *Extractor) Extract(resp HttpResponse) (string, bool) {
+	switch {
+	case e.JSON != "":
+		var jsonData interface{}
+		if err := json.Unmarshal(resp.Body, &jsonData); err != nil {
+			return "", false
+		}
+		value, err := jsonpath.JsonPathLookup(jsonData, e.JSON)
+		if err != nil || value == nil {
+			return "", false
+		}
+		return StringOf(value), true
+	case e.regex != nil:
+		match := e.regex.FindSubmatch(resp.Body)
+		if match == nil {
+			return "", false
+		}
+		if len(match) > 1 {
+			return string(match[1]), true
+		}
+		return string(match[0]), true
+	case e.Header != "":
+		values := resp.Header.Values(e.Header)
+		if len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
+	case e.Cookie != "":
+		for _, cookie := range (&http.Response{Header: resp.Header}).Cookies() {
+			if cookie.Name == e.Cookie {
+				return cookie.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// StringOf renders a value of a json or yaml document, numbers without exponents and objects as json
+func StringOf(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// variablePattern matches a reference to a variable like '${orderId}'
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][\w.-]*)\}`)
+
+// expand replaces the references to the variables, those not defined are kept as they are
+func expand(s string, vars map[string]string) string {
+	if len(vars) == 0 {
+		return s
+	}
+	return variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if value, ok := vars[ref[2:len(ref)-1]]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// extract runs the extractors on the response and stores the values in the variables of the worker,
+// the summary fails if a value is not found, since the following steps would depend on it
+func (w *Worker) extract(extractors []Extractor, resp HttpResponse, summary *Summary) {
+	if w.vars == nil {
+		w.vars = make(map[string]string, len(extractors))
+	}
+	for i := range extractors {
+		value, ok := extractors[i].Extract(resp)
+		if !ok {
+			summary.Success = false
+			summary.FailedAssertion = "Extractor"
+			summary.FailedCause = fmt.Sprintf("nothing was extracted into '%s'", extractors[i].Name)
+			return
+		}
+		w.vars[extractors[i].Name] = value
+	}
+}
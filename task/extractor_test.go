@@ -0,0 +1,108 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractors(t *testing.T) {
+	ast := assert.New(t)
+	resp := HttpResponse{
+		StatusCode: 200,
+		Header: http.Header{
+			"X-Trace-Id": []string{"abc"},
+			"Set-Cookie": []string{"SESSIONID=s1; Path=/", "theme=dark"},
+		},
+		Body: []byte(`{"id": "u-1", "count": 12, "price": 1.5, "tags": ["a"], "token": "token=t0k"}`),
+	}
+	cases := []struct {
+		extractor Extractor
+		expected  string
+	}{
+		{Extractor{Name: "id", JSON: "$.id"}, "u-1"},
+		{Extractor{Name: "count", JSON: "$.count"}, "12"},
+		{Extractor{Name: "price", JSON: "$.price"}, "1.5"},
+		{Extractor{Name: "tags", JSON: "$.tags"}, `["a"]`},
+		{Extractor{Name: "token", Regex: `token=(\w+)`}, "t0k"},
+		{Extractor{Name: "whole", Regex: `"count": \d+`}, `"count": 12`},
+		{Extractor{Name: "trace", Header: "x-trace-id"}, "abc"},
+		{Extractor{Name: "session", Cookie: "SESSIONID"}, "s1"},
+	}
+	for _, c := range cases {
+		ast.Nil(c.extractor.Validate())
+		value, ok := c.extractor.Extract(resp)
+		ast.True(ok, c.extractor.Name)
+		ast.Equal(c.expected, value, c.extractor.Name)
+	}
+	for _, e := range []Extractor{{Name: "a", JSON: "$.missing"}, {Name: "b", Regex: "nothing"}, {Name: "c", Header: "X-None"}, {Name: "d", Cookie: "none"}} {
+		ast.Nil(e.Validate())
+		_, ok := e.Extract(resp)
+		ast.False(ok, e.Name)
+	}
+	ast.NotNil((&Extractor{JSON: "$.id"}).Validate())
+	ast.NotNil((&Extractor{Name: "a"}).Validate())
+	ast.NotNil((&Extractor{Name: "a", JSON: "$.id", Header: "X"}).Validate())
+	ast.NotNil((&Extractor{Name: "a", Regex: "("}).Validate())
+}
+
+func TestExpand(t *testing.T) {
+	ast := assert.New(t)
+	vars := map[string]string{"id": "42", "user.name": "tester"}
+	ast.Equal("/users/42?name=tester", expand("/users/${id}?name=${user.name}", vars))
+	ast.Equal("/orders/${orderId}", expand("/orders/${orderId}", vars))
+	ast.Equal("$id ${ id }", expand("$id ${ id }", vars))
+	ast.Equal("/users/${id}", expand("/users/${id}", nil))
+}
+
+func TestScenarioWithExtractedVariables(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			w.Header().Set("X-Request-Id", "r-7")
+			w.Write([]byte(`{"id": "u-1"}`))
+		case r.URL.Path == "/users/u-1" && r.Header.Get("X-Request-Id") == "r-7":
+			w.Write([]byte(`{"id": "u-1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	s, err := ParseScenario([]byte(`
+steps:
+  - name: create
+    method: post
+    url: ` + server.URL + `/users
+    extract:
+      - {name: userId, json: $.id}
+      - {name: requestId, header: X-Request-Id}
+  - name: get
+    url: ` + server.URL + `/users/${userId}
+    headers: ["X-Request-Id: ${requestId}"]
+    assert: {status: [200]}
+  - name: missing
+    method: post
+    url: ` + server.URL + `/users
+    extract:
+      - {name: none, json: $.none}
+`))
+	ast.Nil(err)
+	w := &Worker{TaskDef: TaskDef{Scenario: s}}
+	w.initClient()
+	summaryChannel := make(chan Summary, 8)
+	w.runScenario(context.Background(), summaryChannel, Summary{})
+	close(summaryChannel)
+	summaries := make([]Summary, 0, 4)
+	for summary := range summaryChannel {
+		summaries = append(summaries, summary)
+	}
+	ast.Len(summaries, 4)
+	ast.True(summaries[1].Success)
+	ast.False(summaries[2].Success)
+	ast.Equal("Extractor", summaries[2].FailedAssertion)
+	ast.Equal(map[string]string{"userId": "u-1", "requestId": "r-7"}, w.vars)
+}
@@ -0,0 +1,179 @@
+package task
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// FeedSequential takes the records in order, shared by all the virtual users
+	FeedSequential string = "sequential"
+	// FeedRandom takes a random record for each iteration
+	FeedRandom string = "random"
+	// FeedUnique gives each virtual user its own record, which it keeps for all its iterations,
+	// the records are never wrapped, a virtual user finding none left stops whatever OnExhausted is
+	FeedUnique string = "unique"
+	// ExhaustedWrap starts over from the first record once all of them are taken
+	ExhaustedWrap string = "wrap"
+	// ExhaustedStop stops the run once all of the records are taken
+	ExhaustedStop string = "stop"
+)
+
+// Feeder provides the records of a data file, whose columns become the variables of the virtual users
+type Feeder struct {
+	Path        string
+	Mode        string
+	OnExhausted string
+	records     []map[string]string
+	cursor      int64
+	// exhausted is called once when the sequential records run out and OnExhausted is ExhaustedStop,
+	// a virtual user finding no unique record just stops by itself
+	exhausted func()
+	stopped   int32
+}
+
+// LoadFeeder reads a CSV file whose first line is the names of the columns, or a JSON-lines file of objects, by the extension
+func LoadFeeder(path string, mode string, onExhausted string) (*Feeder, error) {
+	if mode != FeedSequential && mode != FeedRandom && mode != FeedUnique {
+		return nil, fmt.Errorf("unknown data mode '%s', it should be one of %s, %s and %s", mode, FeedSequential, FeedRandom, FeedUnique)
+	}
+	if onExhausted != ExhaustedWrap && onExhausted != ExhaustedStop {
+		return nil, fmt.Errorf("unknown action '%s' on exhausted data, it should be either %s or %s", onExhausted, ExhaustedWrap, ExhaustedStop)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &Feeder{Path: path, Mode: mode, OnExhausted: onExhausted}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson", ".json":
+		f.records, err = parseJSONLines(data)
+	default:
+		f.records, err = parseCSV(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid data file '%s': %w", path, err)
+	}
+	if len(f.records) == 0 {
+		return nil, fmt.Errorf("no records in the data file '%s'", path)
+	}
+	return f, nil
+}
+
+func parseCSV(data []byte) ([]map[string]string, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	header := rows[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	records := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := make(map[string]string, len(header))
+		for i, name := range header {
+			record[name] = row[i]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func parseJSONLines(data []byte) ([]map[string]string, error) {
+	records := make([]map[string]string, 0, 64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var object map[string]interface{}
+		if err := json.Unmarshal(line, &object); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		record := make(map[string]string, len(object))
+		for k, v := range object {
+			record[k] = StringOf(v)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Len returns the number of records
+func (f *Feeder) Len() int {
+	return len(f.records)
+}
+
+func (f *Feeder) String() string {
+	return fmt.Sprintf("%s (%s, %d records, %s when exhausted)", f.Path, f.Mode, len(f.records), f.OnExhausted)
+}
+
+// take returns the index of the next record of the shared cursor, ok is false if the records are exhausted and not wrapped
+func (f *Feeder) take(wrap bool) (int, bool) {
+	i := int(atomic.AddInt64(&f.cursor, 1) - 1)
+	if i < len(f.records) {
+		return i, true
+	}
+	if wrap {
+		return i % len(f.records), true
+	}
+	return 0, false
+}
+
+// next returns the record for the next iteration of the worker, ok is false if the worker should stop
+func (f *Feeder) next(w *Worker) (map[string]string, bool) {
+	switch f.Mode {
+	case FeedRandom:
+		return f.records[w.rnd.Intn(len(f.records))], true
+	case FeedUnique:
+		if w.record == nil {
+			// wrapping would give the record of another virtual user
+			i, ok := f.take(false)
+			if !ok {
+				return nil, false
+			}
+			w.record = f.records[i]
+		}
+		return w.record, true
+	}
+	i, ok := f.take(f.OnExhausted == ExhaustedWrap)
+	if !ok {
+		// the other virtual users would find them exhausted as well, so the run is stopped at once
+		if atomic.CompareAndSwapInt32(&f.stopped, 0, 1) && f.exhausted != nil {
+			f.exhausted()
+		}
+		return nil, false
+	}
+	return f.records[i], true
+}
+
+// feed sets the columns of the next record as the variables of the worker, it returns false if the data is exhausted
+func (w *Worker) feed() bool {
+	if w.TaskDef.Data == nil {
+		return true
+	}
+	record, ok := w.TaskDef.Data.next(w)
+	if !ok {
+		return false
+	}
+	if w.vars == nil {
+		w.vars = make(map[string]string, len(record))
+	}
+	for k, v := range record {
+		w.vars[k] = v
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package task
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeDataFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFeeder(t *testing.T) {
+	ast := assert.New(t)
+	f, err := LoadFeeder(writeDataFile(t, "users.csv", "id, name\n1,\"Jack, Jr.\"\n2,Mary\n"), FeedSequential, ExhaustedWrap)
+	ast.Nil(err)
+	ast.Equal(2, f.Len())
+	ast.Equal(map[string]string{"id": "1", "name": "Jack, Jr."}, f.records[0])
+
+	f, err = LoadFeeder(writeDataFile(t, "users.jsonl", "{\"id\": 1, \"name\": \"Jack\"}\n\n{\"id\": 2.5, \"tags\": [\"a\"]}\n"), FeedRandom, ExhaustedStop)
+	ast.Nil(err)
+	ast.Equal(2, f.Len())
+	ast.Equal(map[string]string{"id": "1", "name": "Jack"}, f.records[0])
+	ast.Equal(map[string]string{"id": "2.5", "tags": `["a"]`}, f.records[1])
+
+	_, err = LoadFeeder(writeDataFile(t, "empty.csv", "id\n"), FeedSequential, ExhaustedWrap)
+	ast.NotNil(err)
+	_, err = LoadFeeder(writeDataFile(t, "bad.csv", "id,name\n1\n"), FeedSequential, ExhaustedWrap)
+	ast.NotNil(err)
+	_, err = LoadFeeder(writeDataFile(t, "bad.jsonl", "{\"id\": 1}\n[1]\n"), FeedSequential, ExhaustedWrap)
+	ast.NotNil(err)
+	_, err = LoadFeeder(writeDataFile(t, "users.csv", "id\n1\n"), "shuffle", ExhaustedWrap)
+	ast.NotNil(err)
+	_, err = LoadFeeder(writeDataFile(t, "users.csv", "id\n1\n"), FeedSequential, "again")
+	ast.NotNil(err)
+	_, err = LoadFeeder(filepath.Join(t.TempDir(), "none.csv"), FeedSequential, ExhaustedWrap)
+	ast.NotNil(err)
+}
+
+func TestFeederModes(t *testing.T) {
+	ast := assert.New(t)
+	records := []map[string]string{{"id": "1"}, {"id": "2"}, {"id": "3"}}
+	newWorker := func(f *Feeder) *Worker {
+		return &Worker{TaskDef: TaskDef{Data: f}, rnd: rand.New(rand.NewSource(1))}
+	}
+	ids := func(w *Worker, n int) []string {
+		result := make([]string, 0, n)
+		for i := 0; i < n && w.feed(); i++ {
+			result = append(result, w.vars["id"])
+		}
+		return result
+	}
+
+	f := &Feeder{Mode: FeedSequential, OnExhausted: ExhaustedWrap, records: records}
+	a, b := newWorker(f), newWorker(f)
+	ast.Equal([]string{"1", "2"}, ids(a, 2))
+	ast.Equal([]string{"3", "1", "2"}, ids(b, 3))
+
+	stopped := 0
+	f = &Feeder{Mode: FeedSequential, OnExhausted: ExhaustedStop, records: records, exhausted: func() { stopped++ }}
+	a, b = newWorker(f), newWorker(f)
+	ast.Equal([]string{"1", "2", "3"}, ids(a, 5))
+	ast.Empty(ids(b, 5))
+	ast.Equal(1, stopped)
+
+	f = &Feeder{Mode: FeedUnique, OnExhausted: ExhaustedStop, records: records[:2], exhausted: func() { stopped++ }}
+	a, b = newWorker(f), newWorker(f)
+	c := newWorker(f)
+	ast.Equal([]string{"1", "1", "1"}, ids(a, 3))
+	ast.Equal([]string{"2", "2"}, ids(b, 2))
+	// a virtual user without a record stops by itself, the others go on
+	ast.Empty(ids(c, 2))
+	ast.Equal(1, stopped)
+	ast.Equal([]string{"1"}, ids(a, 1))
+
+	// the unique records are not wrapped even if the others would be
+	f = &Feeder{Mode: FeedUnique, OnExhausted: ExhaustedWrap, records: records[:1]}
+	a, b = newWorker(f), newWorker(f)
+	ast.Equal([]string{"1", "1"}, ids(a, 2))
+	ast.Empty(ids(b, 1))
+
+	f = &Feeder{Mode: FeedRandom, OnExhausted: ExhaustedStop, records: records}
+	seen := make(map[string]bool)
+	for _, id := range ids(newWorker(f), 100) {
+		seen[id] = true
+	}
+	ast.Len(seen, 3)
+}
+
+func TestPlanWithData(t *testing.T) {
+	ast := assert.New(t)
+	var mu sync.Mutex
+	paths := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path+" "+r.Header.Get("X-Name")]++
+		mu.Unlock()
+	}))
+	defer server.Close()
+	f, err := LoadFeeder(writeDataFile(t, "users.csv", "id,name\n1,Jack\n2,Mary\n3,Ben\n"), FeedSequential, ExhaustedStop)
+	ast.Nil(err)
+	plan := &Plan{TaskDef: TaskDef{
+		Loop:          100,
+		Concurrency:   2,
+		URL:           server.URL + "/users/${id}",
+		Method:        http.MethodGet,
+		Headers:       []string{"X-Name: ${name}"},
+		Data:          f,
+		DisableBar:    true,
+		DisableReport: true,
+	}}
+	plan.Start()
+	ast.Equal(3, plan.Result().TotalCount())
+	ast.False(plan.Interrupted())
+	ast.Equal(map[string]int{"/users/1 Jack": 1, "/users/2 Mary": 1, "/users/3 Ben": 1}, paths)
+}
+
+func TestArrivalRateWithExhaustedData(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+	f, err := LoadFeeder(writeDataFile(t, "users.csv", "id\n1\n"), FeedUnique, ExhaustedWrap)
+	ast.Nil(err)
+	plan := &Plan{TaskDef: TaskDef{
+		Requests:      3,
+		Concurrency:   2,
+		Rate:          50,
+		URL:           server.URL + "/users/${id}",
+		Method:        http.MethodGet,
+		Data:          f,
+		DisableBar:    true,
+		DisableReport: true,
+	}}
+	plan.Start()
+	// the worker without a record drops the 2nd request and stops, the 3rd one finds no free worker
+	ast.Equal(1, plan.Result().TotalCount())
+	ast.Equal(2, plan.Result().droppedCount)
+}
+
+func TestRequestsWithExhaustedUniqueData(t *testing.T) {
+	ast := assert.New(t)
+	var mu sync.Mutex
+	paths := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+	}))
+	defer server.Close()
+	f, err := LoadFeeder(writeDataFile(t, "users.csv", "id\n1\n2\n"), FeedUnique, ExhaustedWrap)
+	ast.Nil(err)
+	plan := &Plan{TaskDef: TaskDef{
+		Requests:      10,
+		Concurrency:   3,
+		URL:           server.URL + "/users/${id}",
+		Method:        http.MethodGet,
+		Data:          f,
+		DisableBar:    true,
+		DisableReport: true,
+	}}
+	plan.Start()
+	// the worker without a record stops, and its share of the requests is sent by the others
+	ast.Equal(10, plan.Result().TotalCount())
+	ast.Equal(10, paths["/users/1"]+paths["/users/2"])
+	ast.Len(paths, 2)
+}
@@ -0,0 +1,85 @@
+package task
+
+import "fmt"
+
+// GroupedListener passes every summary to the main listener, and also to a SimpleListener of the group it belongs to,
+// so that the report can be broken down by stage and so on
+type GroupedListener struct {
+	main        Listener
+	groupOf     func(summary Summary) string
+	newListener func() SimpleListener
+	names       []string
+	groups      map[string]*SimpleListener
+	// exclusive makes the summaries of a group skip the main listener, e.g. the steps of a scenario are only counted by their iterations
+	exclusive bool
+}
+
+// BuildGroupedListener creates a GroupedListener, newListener creates the listener of each group,
+// summaries whose group is empty are only passed to the main listener
+func BuildGroupedListener(main Listener, newListener func() SimpleListener, groupOf func(summary Summary) string) GroupedListener {
+	return GroupedListener{
+		main:        main,
+		groupOf:     groupOf,
+		newListener: newListener,
+		names:       make([]string, 0, 8),
+		groups:      make(map[string]*SimpleListener, 8),
+	}
+}
+
+// Declare adds groups in advance, so that they are reported in this order even if they get no requests
+func (g *GroupedListener) Declare(names ...string) {
+	for _, name := range names {
+		g.group(name)
+	}
+}
+
+func (g *GroupedListener) group(name string) *SimpleListener {
+	if l, ok := g.groups[name]; ok {
+		return l
+	}
+	l := g.newListener()
+	l.title = name
+	l.measureSpan = true
+	l.OnStart()
+	g.groups[name] = &l
+	g.names = append(g.names, name)
+	return &l
+}
+
+func (g *GroupedListener) OnStart() {
+	g.main.OnStart()
+}
+
+func (g *GroupedListener) OnRequestFinished(summary Summary) {
+	name := g.groupOf(summary)
+	if name == "" || !g.exclusive {
+		g.main.OnRequestFinished(summary)
+	}
+	if name != "" {
+		g.group(name).OnRequestFinished(summary)
+	}
+}
+
+func (g *GroupedListener) OnPlanFinished() Report {
+	report := groupedReport{
+		main:   g.main.OnPlanFinished(),
+		groups: make([]Report, 0, len(g.names)),
+	}
+	for _, name := range g.names {
+		report.groups = append(report.groups, g.groups[name].OnPlanFinished())
+	}
+	return report
+}
+
+type groupedReport struct {
+	main   Report
+	groups []Report
+}
+
+func (r groupedReport) PrintToStdOut() {
+	r.main.PrintToStdOut()
+	for _, group := range r.groups {
+		fmt.Println()
+		group.PrintToStdOut()
+	}
+}
@@ -0,0 +1,474 @@
+package task
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// DefaultHistogramPrecision keeps the percentiles within 0.1% of the exact ones
+const DefaultHistogramPrecision = 3
+
+// LatencyRecorder records latencies and answers the statistics of them
+type LatencyRecorder interface {
+	Record(value int64)
+	// Merge adds all the values of the other recorder into this one
+	Merge(other LatencyRecorder)
+	Count() int64
+	Sum() int64
+	Min() int64
+	Max() int64
+	Mean() float64
+	StdDev() float64
+	// Percentile returns the nearest-rank value at the percentile p, e.g. 99.9
+	Percentile(p float64) int64
+	// forEach calls f for each distinct value with its count, in ascending order
+	forEach(f func(value int64, count int64))
+}
+
+// newRecorder returns an ExactRecorder if the precision is 0, or a Histogram with the precision otherwise
+func newRecorder(precision int) LatencyRecorder {
+	if precision == 0 {
+		return &ExactRecorder{}
+	}
+	return NewHistogram(precision)
+}
+
+// Histogram is a log-linear histogram in the style of HdrHistogram, its memory only grows with the logarithm of the max value.
+// the mean, standard deviation, min and max are exact, the percentiles are within the relative error of ErrorBound
+type Histogram struct {
+	precision int
+	// values below subBucketCount are recorded exactly, every power of 2 above it is split into subBucketHalfCount buckets
+	subBucketHalfCountMagnitude uint
+	subBucketHalfCount          int64
+	subBucketMask               int64
+	counts                      []int64
+	count                       int64
+	min                         int64
+	max                         int64
+	sum                         int64
+	sumOfSquares                float64
+}
+
+// NewHistogram creates a Histogram which keeps the precision in significant decimal digits, from 1 to 5
+func NewHistogram(precision int) *Histogram {
+	precision = clampPrecision(precision)
+	largestWithSingleUnitResolution := 2 * math.Pow10(precision)
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(largestWithSingleUnitResolution)))
+	subBucketCount := int64(1) << subBucketCountMagnitude
+	return &Histogram{
+		precision:                   precision,
+		subBucketHalfCountMagnitude: subBucketCountMagnitude - 1,
+		subBucketHalfCount:          subBucketCount / 2,
+		subBucketMask:               subBucketCount - 1,
+		counts:                      make([]int64, subBucketCount),
+	}
+}
+
+func clampPrecision(precision int) int {
+	if precision < 1 {
+		return 1
+	}
+	if precision > 5 {
+		return 5
+	}
+	return precision
+}
+
+// ErrorBound returns the max relative error of the percentiles, e.g. 0.001 for 3 significant digits
+func (h *Histogram) ErrorBound() float64 {
+	return math.Pow10(-h.precision)
+}
+
+func (h *Histogram) Precision() int {
+	return h.precision
+}
+
+func (h *Histogram) countsIndex(value int64) int {
+	bucket := 64 - bits.LeadingZeros64(uint64(value|h.subBucketMask)) - int(h.subBucketHalfCountMagnitude+1)
+	subBucket := value >> uint(bucket)
+	return (bucket+1)<<h.subBucketHalfCountMagnitude + int(subBucket-h.subBucketHalfCount)
+}
+
+// valueRange returns the lowest value of the index and the number of values sharing it
+func (h *Histogram) valueRange(index int) (int64, int64) {
+	bucket := index>>h.subBucketHalfCountMagnitude - 1
+	subBucket := int64(index)&(h.subBucketHalfCount-1) + h.subBucketHalfCount
+	if bucket < 0 {
+		subBucket -= h.subBucketHalfCount
+		bucket = 0
+	}
+	return subBucket << uint(bucket), int64(1) << uint(bucket)
+}
+
+func (h *Histogram) Record(value int64) {
+	h.recordN(value, 1)
+}
+
+func (h *Histogram) recordN(value int64, n int64) {
+	if n <= 0 {
+		return
+	}
+	if value < 0 {
+		value = 0
+	}
+	index := h.countsIndex(value)
+	if index >= len(h.counts) {
+		grown := make([]int64, index+1, index+1+int(h.subBucketHalfCount))
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	h.counts[index] += n
+	if h.count == 0 || value < h.min {
+		h.min = value
+	}
+	if value > h.max {
+		h.max = value
+	}
+	h.count += n
+	h.sum += value * n
+	h.sumOfSquares += float64(value) * float64(value) * float64(n)
+}
+
+// Merge adds the other recorder, a Histogram of the same precision is merged without any loss
+func (h *Histogram) Merge(other LatencyRecorder) {
+	o, ok := other.(*Histogram)
+	if !ok || o.precision != h.precision {
+		other.forEach(h.recordN)
+		return
+	}
+	if o.count == 0 {
+		return
+	}
+	if len(o.counts) > len(h.counts) {
+		grown := make([]int64, len(o.counts))
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+	for i, c := range o.counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || o.min < h.min {
+		h.min = o.min
+	}
+	if o.max > h.max {
+		h.max = o.max
+	}
+	h.count += o.count
+	h.sum += o.sum
+	h.sumOfSquares += o.sumOfSquares
+}
+
+// Snapshot returns a copy which does not change with this one
+func (h *Histogram) Snapshot() *Histogram {
+	s := *h
+	s.counts = make([]int64, len(h.counts))
+	copy(s.counts, h.counts)
+	return &s
+}
+
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+func (h *Histogram) Sum() int64 {
+	return h.sum
+}
+
+func (h *Histogram) Min() int64 {
+	return h.min
+}
+
+func (h *Histogram) Max() int64 {
+	return h.max
+}
+
+func (h *Histogram) Mean() float64 {
+	if h.count == 0 {
+		return 0
+	}
+	return float64(h.sum) / float64(h.count)
+}
+
+func (h *Histogram) StdDev() float64 {
+	if h.count == 0 {
+		return 0
+	}
+	mean := h.Mean()
+	variance := h.sumOfSquares/float64(h.count) - mean*mean
+	if variance < 0 {
+		return 0
+	}
+	return math.Sqrt(variance)
+}
+
+// Percentile returns the highest value equivalent to the nearest-rank value, bounded by the exact min and max
+func (h *Histogram) Percentile(p float64) int64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			lowest, size := h.valueRange(i)
+			value := lowest + size - 1
+			if value > h.max {
+				value = h.max
+			}
+			if value < h.min {
+				value = h.min
+			}
+			return value
+		}
+	}
+	return h.max
+}
+
+func (h *Histogram) forEach(f func(value int64, count int64)) {
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		lowest, size := h.valueRange(i)
+		f(lowest+size/2, c)
+	}
+}
+
+// ExactRecorder keeps every value, it is accurate but its memory grows with the number of requests
+type ExactRecorder struct {
+	values []int64
+	sorted bool
+}
+
+func (r *ExactRecorder) Record(value int64) {
+	r.values = append(r.values, value)
+	r.sorted = false
+}
+
+func (r *ExactRecorder) Merge(other LatencyRecorder) {
+	other.forEach(func(value int64, count int64) {
+		for i := int64(0); i < count; i++ {
+			r.values = append(r.values, value)
+		}
+	})
+	r.sorted = false
+}
+
+func (r *ExactRecorder) sort() {
+	if !r.sorted {
+		sort.Slice(r.values, func(i, j int) bool { return r.values[i] < r.values[j] })
+		r.sorted = true
+	}
+}
+
+func (r *ExactRecorder) Count() int64 {
+	return int64(len(r.values))
+}
+
+func (r *ExactRecorder) Sum() int64 {
+	var sum int64
+	for _, v := range r.values {
+		sum += v
+	}
+	return sum
+}
+
+func (r *ExactRecorder) Min() int64 {
+	if len(r.values) == 0 {
+		return 0
+	}
+	r.sort()
+	return r.values[0]
+}
+
+func (r *ExactRecorder) Max() int64 {
+	if len(r.values) == 0 {
+		return 0
+	}
+	r.sort()
+	return r.values[len(r.values)-1]
+}
+
+func (r *ExactRecorder) Mean() float64 {
+	if len(r.values) == 0 {
+		return 0
+	}
+	return float64(r.Sum()) / float64(len(r.values))
+}
+
+func (r *ExactRecorder) StdDev() float64 {
+	if len(r.values) == 0 {
+		return 0
+	}
+	mean := r.Mean()
+	var sd float64
+	for _, v := range r.values {
+		sd += math.Pow(float64(v)-mean, 2)
+	}
+	return math.Sqrt(sd / float64(len(r.values)))
+}
+
+func (r *ExactRecorder) Percentile(p float64) int64 {
+	r.sort()
+	return percentileOf(r.values, p)
+}
+
+func (r *ExactRecorder) forEach(f func(value int64, count int64)) {
+	r.sort()
+	for _, v := range r.values {
+		f(v, 1)
+	}
+}
+
+// exactUnion answers the statistics of the values of several ExactRecorders without copying them,
+// so that the latencies of all the outcomes are not kept twice in the exact mode
+type exactUnion struct {
+	parts []*ExactRecorder
+}
+
+// Record adds the value to the first part
+func (u *exactUnion) Record(value int64) {
+	u.parts[0].Record(value)
+}
+
+// Merge adds the values of the other recorder to the first part
+func (u *exactUnion) Merge(other LatencyRecorder) {
+	u.parts[0].Merge(other)
+}
+
+func (u *exactUnion) Count() int64 {
+	var count int64
+	for _, r := range u.parts {
+		count += r.Count()
+	}
+	return count
+}
+
+func (u *exactUnion) Sum() int64 {
+	var sum int64
+	for _, r := range u.parts {
+		sum += r.Sum()
+	}
+	return sum
+}
+
+func (u *exactUnion) Min() int64 {
+	var min int64
+	found := false
+	for _, r := range u.parts {
+		if r.Count() > 0 && (!found || r.Min() < min) {
+			min = r.Min()
+			found = true
+		}
+	}
+	return min
+}
+
+func (u *exactUnion) Max() int64 {
+	var max int64
+	found := false
+	for _, r := range u.parts {
+		if r.Count() > 0 && (!found || r.Max() > max) {
+			max = r.Max()
+			found = true
+		}
+	}
+	return max
+}
+
+func (u *exactUnion) Mean() float64 {
+	count := u.Count()
+	if count == 0 {
+		return 0
+	}
+	return float64(u.Sum()) / float64(count)
+}
+
+func (u *exactUnion) StdDev() float64 {
+	count := u.Count()
+	if count == 0 {
+		return 0
+	}
+	mean := u.Mean()
+	var sd float64
+	for _, r := range u.parts {
+		for _, v := range r.values {
+			sd += math.Pow(float64(v)-mean, 2)
+		}
+	}
+	return math.Sqrt(sd / float64(count))
+}
+
+// Median returns the middle value, or the mean of the two middle values if the count is even
+func (u *exactUnion) Median() float64 {
+	l := int(u.Count())
+	if l == 0 {
+		return 0
+	}
+	if l%2 == 0 {
+		return float64(u.nth(l/2-1)+u.nth(l/2)) / 2
+	}
+	return float64(u.nth(l / 2))
+}
+
+func (u *exactUnion) Percentile(p float64) int64 {
+	l := int(u.Count())
+	if l == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(l)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > l {
+		rank = l
+	}
+	return u.nth(rank - 1)
+}
+
+// nth returns the n-th smallest value from 0, by walking the sorted parts together
+func (u *exactUnion) nth(n int) int64 {
+	var value int64
+	u.forEachUntil(func(v int64) bool {
+		value = v
+		n--
+		return n >= 0
+	})
+	return value
+}
+
+func (u *exactUnion) forEach(f func(value int64, count int64)) {
+	u.forEachUntil(func(v int64) bool {
+		f(v, 1)
+		return true
+	})
+}
+
+// forEachUntil calls f for each value in ascending order until it returns false
+func (u *exactUnion) forEachUntil(f func(value int64) bool) {
+	heads := make([]int, len(u.parts))
+	for _, r := range u.parts {
+		r.sort()
+	}
+	for {
+		next := -1
+		for i, r := range u.parts {
+			if heads[i] < len(r.values) && (next < 0 || r.values[heads[i]] < u.parts[next].values[heads[next]]) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return
+		}
+		v := u.parts[next].values[heads[next]]
+		heads[next]++
+		if !f(v) {
+			return
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package task
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramSmallValuesAreExact(t *testing.T) {
+	ast := assert.New(t)
+	h := NewHistogram(3)
+	for _, v := range []int64{5, 10, 4, 3, 9, 8, 1, 23, 12, 2} {
+		h.Record(v)
+	}
+	ast.Equal(int64(10), h.Count())
+	ast.Equal(int64(77), h.Sum())
+	ast.Equal(int64(1), h.Min())
+	ast.Equal(int64(23), h.Max())
+	ast.Equal(7.7, h.Mean())
+	ast.InDelta(6.1652, h.StdDev(), 0.0001)
+	ast.Equal(int64(5), h.Percentile(50))
+	ast.Equal(int64(23), h.Percentile(99))
+	ast.Equal(int64(1), h.Percentile(0))
+}
+
+func TestHistogramErrorBound(t *testing.T) {
+	ast := assert.New(t)
+	r := rand.New(rand.NewSource(1))
+	for precision := 1; precision <= 4; precision++ {
+		h := NewHistogram(precision)
+		exact := &ExactRecorder{}
+		for i := 0; i < 100000; i++ {
+			// log-normal latencies around 20ms in nanoseconds
+			v := int64(math.Exp(r.NormFloat64() + 17))
+			h.Record(v)
+			exact.Record(v)
+		}
+		ast.Equal(exact.Min(), h.Min())
+		ast.Equal(exact.Max(), h.Max())
+		ast.InDelta(exact.Mean(), h.Mean(), 1)
+		for _, p := range []float64{50, 90, 99, 99.9} {
+			e := exact.Percentile(p)
+			ast.InEpsilon(e, h.Percentile(p), h.ErrorBound(), "p%g of precision %d", p, precision)
+		}
+	}
+}
+
+func TestHistogramMemory(t *testing.T) {
+	ast := assert.New(t)
+	h := NewHistogram(3)
+	for i := int64(0); i < 1000000; i++ {
+		h.Record(i * 1000)
+	}
+	// an hour in nanoseconds still needs less than 40k counters
+	h.Record(int64(3600e9))
+	ast.Less(len(h.counts), 40000)
+	ast.Equal(int64(3600e9), h.Max())
+}
+
+func TestHistogramMerge(t *testing.T) {
+	ast := assert.New(t)
+	a, b, all := NewHistogram(3), NewHistogram(3), NewHistogram(3)
+	for i := int64(1); i <= 1000; i++ {
+		a.Record(i * 1000)
+		all.Record(i * 1000)
+	}
+	for i := int64(1); i <= 10; i++ {
+		b.Record(i * 1000000)
+		all.Record(i * 1000000)
+	}
+	snapshot := a.Snapshot()
+	a.Merge(b)
+	ast.Equal(all.Count(), a.Count())
+	ast.Equal(all.Min(), a.Min())
+	ast.Equal(all.Max(), a.Max())
+	ast.Equal(all.Mean(), a.Mean())
+	for _, p := range []float64{50, 99, 99.9} {
+		ast.Equal(all.Percentile(p), a.Percentile(p))
+	}
+	// the snapshot does not change with the merge
+	ast.Equal(int64(1000), snapshot.Count())
+	ast.Equal(int64(1000000), snapshot.Max())
+
+	// recorders of other kinds are merged by their values
+	exact := &ExactRecorder{}
+	exact.Merge(b)
+	ast.Equal(int64(10), exact.Count())
+	ast.InEpsilon(int64(10000000), exact.Max(), 0.001)
+	h := NewHistogram(2)
+	h.Merge(exact)
+	ast.Equal(int64(10), h.Count())
+}
+
+func TestListenerWithHistogram(t *testing.T) {
+	ast := assert.New(t)
+	l := BuildSimpleListener(0, "ms")
+	l.UseHistogram(3)
+	l.OnStart()
+	now := time.Now()
+	for i := 1; i <= 10; i++ {
+		l.OnRequestFinished(Summary{StartTime: now, EndTime: now.Add(time.Duration(i) * time.Millisecond), Success: true})
+		l.OnRequestFinished(Summary{StartTime: now, EndTime: now.Add(time.Duration(i*100) * time.Millisecond), HasError: true})
+	}
+	l.OnPlanFinished()
+	ast.IsType(&Histogram{}, l.all)
+	ast.Equal(int64(20), l.all.Count())
+	ast.Equal(time.Millisecond, time.Duration(l.min))
+	ast.Equal(time.Second, l.Max())
+	// the percentiles are within the error bound, the min and max are exact
+	ast.InEpsilon(10*time.Millisecond, l.Percentile(50), 0.001)
+	ast.Equal(time.Second, l.Percentile(99.9))
+	ast.InEpsilon(500*time.Millisecond, time.Duration(l.errorLatency.Percentile(50)), 0.001)
+}
+
+func TestExactUnion(t *testing.T) {
+	ast := assert.New(t)
+	parts := []*ExactRecorder{{values: []int64{5, 1, 9}}, {}, {values: []int64{4, 8, 2, 7}}}
+	merged := &ExactRecorder{}
+	for _, r := range parts {
+		merged.Merge(r)
+	}
+	u := &exactUnion{parts: parts}
+	ast.Equal(int64(7), u.Count())
+	ast.Equal(merged.Sum(), u.Sum())
+	ast.Equal(int64(1), u.Min())
+	ast.Equal(int64(9), u.Max())
+	ast.Equal(merged.StdDev(), u.StdDev())
+	ast.Equal(float64(5), u.Median())
+	for _, p := range []float64{0, 10, 50, 75, 99, 100} {
+		ast.Equal(merged.Percentile(p), u.Percentile(p), p)
+	}
+	// the parts are not copied
+	ast.Len(parts[0].values, 3)
+	u.Record(3)
+	ast.Equal(float64(4.5), u.Median())
+
+	empty := &exactUnion{parts: []*ExactRecorder{{}, {}}}
+	ast.Zero(empty.Min())
+	ast.Zero(empty.Percentile(99))
+	ast.Zero(empty.Median())
+}
@@ -2,9 +2,10 @@ package task
 
 import (
 	"fmt"
-	"log"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,58 +19,207 @@ type Report interface {
 	PrintToStdOut()
 }
 
+// DefaultPercentiles are reported unless the percentiles are specified
+var DefaultPercentiles = []float64{50, 75, 90, 95, 99, 99.9}
+
+// ParsePercentiles parses percentiles like '50,90,95,99,99.9'
+func ParsePercentiles(percentiles string) ([]float64, error) {
+	result := make([]float64, 0, 8)
+	for _, item := range strings.Split(percentiles, ",") {
+		item = strings.TrimPrefix(strings.TrimSpace(item), "p")
+		if item == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentile '%s': %w", item, err)
+		}
+		if p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile '%s', it must be in (0, 100]", item)
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
 type SimpleListener struct {
-	costs []int64
+	// latencies of each outcome, for the percentiles, every one of them is kept in the exact mode
+	successLatency LatencyRecorder
+	failedLatency  LatencyRecorder
+	errorLatency   LatencyRecorder
+	// all is the latencies of all the requests, it is set by calculate
+	all LatencyRecorder
+	// phases are the latencies of each phase of Phases, they are nil until a phase is recorded
+	phases      []LatencyRecorder
+	newConns    int
+	reusedConns int
+	// precision of the histograms in significant digits, 0 for the exact mode which keeps every latency
+	precision   int
+	percentiles []float64
 	// costsOfPreSending      []int64
 	// costsOfPostSending     []int64
 	index        int
 	successCount int
 	failedCount  int
 	errorCount   int
-	totalCost    int64
+	droppedCount int
+	lateCount    int
+	// openModel is true if the requests are scheduled by an arrival rate, then the dropped and late requests are reported
+	openModel bool
+	// reportWorkers is true if the number of requests of each worker is reported
+	reportWorkers bool
+	workerCounts  map[int]int
+	// timeline is nil unless the interval is set by EnableTimeline
+	timeline      *timeline
+	printTimeline bool
+	totalCost     int64
 	// totalCostOfPreSending  int64
 	// totalCostOfPostSending int64
-	natureDuration  time.Duration
-	start           time.Time
-	end             time.Time
-	mean            float64
-	max             int64
-	min             int64
-	median          float64
-	stdDev          float64
-	calculated      bool
-	timeunit        string
+	// title of the report, "Conclusion" by default
+	title string
+	// measureSpan makes the nature duration span from the first request to the last one, instead of from OnStart to OnPlanFinished
+	measureSpan    bool
+	firstStart     time.Time
+	lastEnd        time.Time
+	natureDuration time.Duration
+	start          time.Time
+	end            time.Time
+	mean           float64
+	max            int64
+	min            int64
+	median         float64
+	stdDev         float64
+	calculated     bool
+	timeunit       string
+	// auto is true if the timeunit was picked by the median, see resolveTimeunit
+	auto            bool
 	timeunitDivisor int64
 	throughput      int64
 }
 
+// BuildSimpleListener creates a listener, the capacity is only a hint since a duration-based plan does not know the count in advance.
+// the latencies are kept in nanoseconds, the timeunit only affects how the report is printed, Auto picks it by the median
 func BuildSimpleListener(capacity int, timeunit string) SimpleListener {
-	var d int64
+	l := SimpleListener{
+		// costsOfPreSending:  make([]int64, capacity),
+		// costsOfPostSending: make([]int64, capacity),
+		successLatency: &ExactRecorder{values: make([]int64, 0, capacity)},
+		failedLatency:  &ExactRecorder{},
+		errorLatency:   &ExactRecorder{},
+		percentiles:    DefaultPercentiles,
+	}
+	if timeunit == Auto {
+		l.timeunit = Auto
+	} else {
+		l.setTimeunit(timeunit)
+	}
+	return l
+}
+
+func (s *SimpleListener) setTimeunit(timeunit string) {
 	if timeunit == NanoSecond {
-		d = 1
+		s.timeunitDivisor = NanoSecondDivisor
 	} else if timeunit == MicroSecond {
-		d = MicroSecondDivisor
+		s.timeunitDivisor = MicroSecondDivisor
 	} else if timeunit == MilliSecond {
-		d = MilliSecondDivisor
+		s.timeunitDivisor = MilliSecondDivisor
 	} else if timeunit == Second {
-		d = SecondDivisor
+		s.timeunitDivisor = SecondDivisor
 	} else {
 		// log.Panicf("unknown timeunit %s\n", timeunit)
-		timeunit = "ms"
-		d = MilliSecondDivisor
+		timeunit = MilliSecond
+		s.timeunitDivisor = MilliSecondDivisor
 	}
-	return SimpleListener{
-		costs: make([]int64, capacity),
-		// costsOfPreSending:  make([]int64, capacity),
-		// costsOfPostSending: make([]int64, capacity),
-		timeunit:        timeunit,
-		timeunitDivisor: d,
+	s.timeunit = timeunit
+}
+
+// resolveTimeunit picks the unit of the latencies by the median, if the timeunit is Auto
+func (s *SimpleListener) resolveTimeunit() {
+	if s.timeunit != Auto {
+		return
+	}
+	s.auto = true
+	s.setTimeunit(unitOf(s.median))
+}
+
+// unitOf returns the largest unit in which the value in nanoseconds is at least 1
+func unitOf(ns float64) string {
+	switch {
+	case ns >= float64(SecondDivisor):
+		return Second
+	case ns >= float64(MilliSecondDivisor):
+		return MilliSecond
+	case ns >= float64(MicroSecondDivisor):
+		return MicroSecond
+	default:
+		return NanoSecond
+	}
+}
+
+// format renders a latency in nanoseconds in the timeunit of the report
+func (s *SimpleListener) format(ns float64) string {
+	if s.timeunit == NanoSecond {
+		return strconv.FormatFloat(ns, 'f', 0, 64)
 	}
+	return strconv.FormatFloat(ns/float64(s.timeunitDivisor), 'f', 3, 64)
 }
+
+// formatSpan renders a duration which is not a latency, e.g. the nature duration, followed by its unit.
+// in the Auto mode the unit is picked by the value itself, since a run is much longer than its requests
+func (s *SimpleListener) formatSpan(ns float64) string {
+	if !s.auto {
+		return s.format(ns) + " " + s.timeunit
+	}
+	span := SimpleListener{}
+	span.setTimeunit(unitOf(ns))
+	return span.format(ns) + " " + span.timeunit
+}
+
+// UseHistogram records the latencies in histograms of the precision in significant digits instead of keeping every one of them,
+// so that the memory does not grow with the number of requests
+func (s *SimpleListener) UseHistogram(precision int) {
+	s.precision = clampPrecision(precision)
+	s.successLatency = NewHistogram(precision)
+	s.failedLatency = NewHistogram(precision)
+	s.errorLatency = NewHistogram(precision)
+}
+
+// EnableTimeline keeps the statistics of each interval, and prints them at the end if print is true
+func (s *SimpleListener) EnableTimeline(interval time.Duration, print bool) {
+	if interval <= 0 {
+		return
+	}
+	s.timeline = &timeline{interval: interval}
+	s.printTimeline = print
+}
+
 func (s *SimpleListener) OnStart() {
 	s.start = time.Now()
 }
 func (s *SimpleListener) OnRequestFinished(summary Summary) {
+	if summary.Dropped {
+		s.droppedCount++
+		return
+	}
+	if summary.Late {
+		s.lateCount++
+	}
+	if s.reportWorkers {
+		if s.workerCounts == nil {
+			s.workerCounts = make(map[int]int, 64)
+		}
+		s.workerCounts[summary.WorkerID]++
+	}
+	if s.timeline != nil {
+		s.timeline.add(s.start, summary)
+	}
+	s.recordPhases(summary)
+	if s.firstStart.IsZero() || summary.StartTime.Before(s.firstStart) {
+		s.firstStart = summary.StartTime
+	}
+	if summary.EndTime.After(s.lastEnd) {
+		s.lastEnd = summary.EndTime
+	}
 	if summary.HasError {
 		s.errorCount++
 	} else if summary.Success {
@@ -78,22 +228,17 @@ func (s *SimpleListener) OnRequestFinished(summary Summary) {
 		s.failedCount++
 	}
 	// costPreSending := summary.StartTime.Sub(summary.StartTimeOfAll).Nanoseconds()
-	cost := summary.EndTime.Sub(summary.StartTime).Nanoseconds()
+	cost := summary.Latency().Nanoseconds()
 	// costPostSending := summary.EndTimeOfAll.Sub(summary.EndTime).Nanoseconds()
-	// log.Printf("costPreSending: %d, costPreSending: %d, cost: %d, costPostSending: %d, timeunitDivisor: %d\n", costPreSending, costPreSending/s.timeunitDivisor, cost/s.timeunitDivisor, costPostSending/s.timeunitDivisor, s.timeunitDivisor)
 	// s.total += cost
-	// append(s.costsOfPreSending, costPreSending/s.timeunitDivisor, s.index)
-	appendToSlice(s.costs, cost/s.timeunitDivisor, s.index)
-	// append(s.costsOfPostSending, costPostSending/s.timeunitDivisor, s.index)
-	s.index++
-}
-
-func appendToSlice(arr []int64, item int64, index int) {
-	if index >= len(arr) {
-		log.Printf("out of slice capacity, capacity: %d", len(arr))
-		return
+	if summary.HasError {
+		s.errorLatency.Record(cost)
+	} else if summary.Success {
+		s.successLatency.Record(cost)
+	} else {
+		s.failedLatency.Record(cost)
 	}
-	arr[index] = item
+	s.index++
 }
 
 // func (s *SimpleListener) OnWorkerFinished(workerID int) {
@@ -104,80 +249,200 @@ func (s *SimpleListener) OnPlanFinished() Report {
 	}
 	s.end = time.Now()
 	s.natureDuration = s.end.Sub(s.start)
+	if s.timeline != nil {
+		s.timeline.calculate()
+	}
+	if s.measureSpan {
+		s.natureDuration = s.lastEnd.Sub(s.firstStart)
+	}
 	s.calculate()
 	return s
 }
 
 func (s *SimpleListener) PrintToStdOut() {
-	fmt.Println("-- Conclusion --")
+	title := s.title
+	if title == "" {
+		title = "Conclusion"
+	}
+	fmt.Printf("-- %s --\n", title)
 	fmt.Printf("total count: %d\n", s.successCount+s.failedCount+s.errorCount)
 	fmt.Printf("success count: %d\n", s.successCount)
 	fmt.Printf("failed count: %d\n", s.failedCount)
 	fmt.Printf("error count: %d\n", s.errorCount)
-	fmt.Printf("nature duration: %d %s\n", s.natureDuration/time.Duration(s.timeunitDivisor), s.timeunit)
-	fmt.Printf("total cost: %d %s\n", s.totalCost, s.timeunit)
-	fmt.Printf("max: %d %s\n", s.max, s.timeunit)
-	fmt.Printf("min: %d %s\n", s.min, s.timeunit)
-	fmt.Printf("median: %d %s\n", int64(s.median), s.timeunit)
-	fmt.Printf("mean: %d %s\n", int64(s.mean), s.timeunit)
-	fmt.Printf("standard deviation: %f\n", s.stdDev)
+	if s.openModel {
+		fmt.Printf("late count: %d\n", s.lateCount)
+		fmt.Printf("dropped count: %d\n", s.droppedCount)
+	}
+	fmt.Printf("nature duration: %s\n", s.formatSpan(float64(s.natureDuration)))
+	fmt.Printf("total cost: %s\n", s.formatSpan(float64(s.totalCost)))
+	fmt.Printf("max: %s %s\n", s.format(float64(s.max)), s.timeunit)
+	fmt.Printf("min: %s %s\n", s.format(float64(s.min)), s.timeunit)
+	fmt.Printf("median: %s %s\n", s.format(s.median), s.timeunit)
+	fmt.Printf("mean: %s %s\n", s.format(s.mean), s.timeunit)
+	fmt.Printf("standard deviation: %s %s\n", s.format(s.stdDev), s.timeunit)
 	fmt.Printf("throughput: %d requests/second\n", s.throughput)
+	s.printPercentiles()
+	if h, ok := s.all.(*Histogram); ok && h.Count() > 0 {
+		fmt.Printf("percentiles error: within %g%% (histogram of %d significant digits)\n", h.ErrorBound()*100, h.Precision())
+	}
+	s.printPhases()
+	if s.reportWorkers {
+		s.printWorkerCounts()
+	}
+	if s.timeline != nil && s.printTimeline {
+		fmt.Println()
+		s.printTimelineTable()
+	}
 	// fmt.Printf("len: %d, costs: %+v\n", len(s.costs), s.costs)
 }
 
+// printPercentiles prints the percentiles of all the requests, and of each outcome which has requests
+func (s *SimpleListener) printPercentiles() {
+	if len(s.percentiles) == 0 || s.all == nil || s.all.Count() == 0 {
+		return
+	}
+	fmt.Printf("percentiles (%s):", s.timeunit)
+	for _, p := range s.percentiles {
+		fmt.Printf("\tp%g", p)
+	}
+	fmt.Println()
+	rows := []struct {
+		name      string
+		latencies LatencyRecorder
+	}{
+		{"all", s.all},
+		{"success", s.successLatency},
+		{"failed", s.failedLatency},
+		{"error", s.errorLatency},
+	}
+	for _, row := range rows {
+		if row.latencies.Count() == 0 {
+			continue
+		}
+		fmt.Printf("  %s", row.name)
+		for _, p := range s.percentiles {
+			fmt.Printf("\t%s", s.format(float64(row.latencies.Percentile(p))))
+		}
+		fmt.Println()
+	}
+}
+
+// printWorkerCounts prints how many requests each worker sent, so that the imbalance can be seen
+func (s *SimpleListener) printWorkerCounts() {
+	ids := make([]int, 0, len(s.workerCounts))
+	for id := range s.workerCounts {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	sort.Ints(ids)
+	min, max := s.workerCounts[ids[0]], s.workerCounts[ids[0]]
+	for _, id := range ids {
+		if n := s.workerCounts[id]; n < min {
+			min = n
+		} else if n > max {
+			max = n
+		}
+	}
+	fmt.Printf("requests per worker: min %d, max %d, mean %.1f\n", min, max, float64(s.TotalCount())/float64(len(ids)))
+	for _, id := range ids {
+		fmt.Printf("  worker %d: %d\n", id, s.workerCounts[id])
+	}
+}
+
 func (s *SimpleListener) calculate() {
 	if s.calculated {
 		return
 	}
 
 	totalCount := s.successCount + s.failedCount + s.errorCount
-	s.totalCost = 0
-	// s.totalCostOfPreSending = 0
-	// s.totalCostOfPostSending = 0
-	// fmt.Printf("s.costs: %+v\n", s.costs)
-	// fmt.Printf("s.costsOfPreSending: %+v\n", s.costsOfPreSending)
-
-	for _, cost := range s.costs {
-		s.totalCost += cost
-	}
-	// for _, cost := range s.costsOfPreSending {
-	// 	s.totalCostOfPreSending += cost
-	// }
-	// for _, cost := range s.costsOfPostSending {
-	// 	s.totalCostOfPostSending += cost
-	// }
+	if s.precision == 0 {
+		// the latencies are kept once by the outcomes, all of them are only a view of those
+		s.all = &exactUnion{parts: []*ExactRecorder{
+			s.successLatency.(*ExactRecorder), s.failedLatency.(*ExactRecorder), s.errorLatency.(*ExactRecorder)}}
+	} else {
+		// the histograms of the outcomes are merged without any loss
+		all := NewHistogram(s.precision)
+		all.Merge(s.successLatency)
+		all.Merge(s.failedLatency)
+		all.Merge(s.errorLatency)
+		s.all = all
+	}
+	s.totalCost = s.all.Sum()
+	l := s.all.Count()
+	if totalCount == 0 || l == 0 {
+		s.resolveTimeunit()
+		s.calculated = true
+		return
+	}
 	s.mean = float64(s.totalCost) / float64(totalCount)
+	s.min = s.all.Min()
+	s.max = s.all.Max()
 
-	sort.Slice(s.costs, func(i, j int) bool { return s.costs[i] < s.costs[j] })
-	s.min = s.costs[0]
-
-	l := len(s.costs)
-	s.max = s.costs[l-1]
-
-	if l > 1 {
-		if l%2 == 0 {
-			// even
-			left := s.costs[l/2-1]
-			right := s.costs[l/2]
-			s.median = float64(left+right) / 2
-		} else {
-			// odd
-			s.median = float64(s.costs[(l-1)/2])
-		}
-	} else if l == 1 {
-		s.median = float64(s.costs[0])
+	if exact, ok := s.all.(*exactUnion); ok {
+		s.median = exact.Median()
 	} else {
-		s.median = 0
+		s.median = float64(s.all.Percentile(50))
 	}
 
 	// log.Printf("s.successCount: %d ms, s.costDuration: %d ms\n", s.successCount, s.costDuration.Milliseconds())
-	s.throughput = int64(float64(s.successCount*1000*1000*1000) / float64(s.natureDuration.Nanoseconds()))
-
-	var sd float64
-	for _, cost := range s.costs {
-		sd += math.Pow(math.Abs(float64(cost)-s.mean), 2)
+	if s.natureDuration > 0 {
+		s.throughput = int64(float64(s.successCount*1000*1000*1000) / float64(s.natureDuration.Nanoseconds()))
 	}
-	sd = math.Sqrt(sd / float64(l))
-	s.stdDev = sd
+	s.stdDev = s.all.StdDev()
+	s.resolveTimeunit()
 	s.calculated = true
 }
+
+// TotalCount returns the number of requests that were sent
+func (s *SimpleListener) TotalCount() int {
+	return s.successCount + s.failedCount + s.errorCount
+}
+
+// ErrorRate returns the ratio of the requests that had errors, failed the assertions or were dropped
+func (s *SimpleListener) ErrorRate() float64 {
+	total := s.TotalCount() + s.droppedCount
+	if total == 0 {
+		return 0
+	}
+	return float64(s.errorCount+s.failedCount+s.droppedCount) / float64(total)
+}
+
+// Throughput returns the successful requests per second
+func (s *SimpleListener) Throughput() int64 {
+	return s.throughput
+}
+
+// Mean returns the mean latency, it is only available after OnPlanFinished
+func (s *SimpleListener) Mean() time.Duration {
+	return time.Duration(s.mean)
+}
+
+// Max returns the max latency, it is only available after OnPlanFinished
+func (s *SimpleListener) Max() time.Duration {
+	return time.Duration(s.max)
+}
+
+// Percentile returns the latency at the percentile p, e.g. 99 for p99, it is only available after OnPlanFinished
+func (s *SimpleListener) Percentile(p float64) time.Duration {
+	if s.all == nil {
+		return 0
+	}
+	return time.Duration(s.all.Percentile(p))
+}
+
+// percentileOf returns the nearest-rank percentile of the sorted values
+func percentileOf(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,7 +48,7 @@ func TestCalculate(t *testing.T) {
 	ast := assert.New(t)
 	l := BuildSimpleListener(10, "ms")
 	l.successCount = 10
-	l.costs = []int64{5, 10, 4, 3, 9, 8, 1, 23, 12, 2}
+	l.successLatency = &ExactRecorder{values: []int64{5, 10, 4, 3, 9, 8, 1, 23, 12, 2}}
 	l.calculate()
 	ast.Equal(float64(7.7), l.mean)
 	ast.Equal(int64(77), l.totalCost)
@@ -61,10 +62,66 @@ func TestCalculate2(t *testing.T) {
 	ast := assert.New(t)
 	l := BuildSimpleListener(10, "ms")
 	l.successCount = 10
-	l.costs = []int64{2, 2, 2, 2, 2, 2, 2, 2, 2, 2}
+	l.successLatency = &ExactRecorder{values: []int64{2, 2, 2, 2, 2, 2, 2, 2, 2, 2}}
 	l.calculate()
 	ast.Equal(float64(2), l.mean)
 	ast.Equal(int64(20), l.totalCost)
 	ast.Equal(float64(0), l.stdDev)
 	ast.Equal(float64(2), l.median)
 }
+
+func TestParsePercentiles(t *testing.T) {
+	ast := assert.New(t)
+	p, err := ParsePercentiles("50, 90,p95,99.9")
+	ast.Nil(err)
+	ast.Equal([]float64{50, 90, 95, 99.9}, p)
+	_, err = ParsePercentiles("0")
+	ast.NotNil(err)
+	_, err = ParsePercentiles("101")
+	ast.NotNil(err)
+	_, err = ParsePercentiles("abc")
+	ast.NotNil(err)
+}
+
+func TestPercentilesPerOutcome(t *testing.T) {
+	ast := assert.New(t)
+	l := BuildSimpleListener(0, "ms")
+	l.OnStart()
+	now := time.Now()
+	for i := 1; i <= 10; i++ {
+		l.OnRequestFinished(Summary{StartTime: now, EndTime: now.Add(time.Duration(i) * time.Millisecond), Success: true})
+		l.OnRequestFinished(Summary{StartTime: now, EndTime: now.Add(time.Duration(i*100) * time.Millisecond), HasError: true})
+	}
+	l.OnPlanFinished()
+	ast.Equal(5*time.Millisecond, time.Duration(l.successLatency.Percentile(50)))
+	ast.Equal(500*time.Millisecond, time.Duration(l.errorLatency.Percentile(50)))
+	ast.Equal(time.Second, time.Duration(l.errorLatency.Percentile(99)))
+	ast.Zero(l.failedLatency.Count())
+	ast.Equal(10*time.Millisecond, l.Percentile(50))
+	ast.Equal(time.Second, l.Percentile(99.9))
+}
+
+func TestSubMillisecondLatencies(t *testing.T) {
+	ast := assert.New(t)
+	l := BuildSimpleListener(0, "ms")
+	l.OnStart()
+	now := time.Now()
+	for i := 1; i <= 4; i++ {
+		l.OnRequestFinished(Summary{StartTime: now, EndTime: now.Add(time.Duration(i*100) * time.Microsecond), Success: true})
+	}
+	l.OnPlanFinished()
+	ast.Equal(100*time.Microsecond, time.Duration(l.min))
+	ast.Equal(float64(250*time.Microsecond), l.median)
+	ast.InDelta(float64(111803), l.stdDev, 1)
+	ast.Equal("0.250", l.format(l.median))
+
+	auto := BuildSimpleListener(0, Auto)
+	auto.OnStart()
+	auto.OnRequestFinished(Summary{StartTime: now, EndTime: now.Add(1500 * time.Microsecond), Success: true})
+	auto.OnPlanFinished()
+	ast.Equal(MilliSecond, auto.timeunit)
+	ast.Equal("1.500", auto.format(auto.median))
+	// the nature duration is not printed in the unit of the latencies
+	ast.Equal("2.000 s", auto.formatSpan(float64(2*time.Second)))
+	ast.Equal("2000.000 ms", l.formatSpan(float64(2*time.Second)))
+}
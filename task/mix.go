@@ -0,0 +1,128 @@
+package task
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mix is a set of requests with weights, each iteration sends one of them picked at random by the weights,
+// e.g. 70% of listing the users, 20% of getting one and 10% of creating one
+type Mix struct {
+	Name     string            `yaml:"name"`
+	Requests []WeightedRequest `yaml:"requests"`
+	// total is the sum of the weights
+	total int
+}
+
+// WeightedRequest is a request of the mix, in the same form as a step of a scenario
+type WeightedRequest struct {
+	Step `yaml:",inline"`
+	// Weight is relative to the weights of the other requests, 1 if it is omitted, and 0 disables the request
+	Weight *int `yaml:"weight"`
+	// weight is the Weight resolved by Validate
+	weight int
+}
+
+// LoadMix reads a mix from a YAML or JSON file
+func LoadMix(path string) (*Mix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMix(data)
+}
+
+// ParseMix parses a mix in YAML or JSON, and validates it
+func ParseMix(data []byte) (*Mix, error) {
+	m := &Mix{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid mix: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Validate checks the requests like the steps of a scenario, and sums up their weights
+func (m *Mix) Validate() error {
+	if len(m.Requests) == 0 {
+		return fmt.Errorf("at least 1 request is required by the mix")
+	}
+	names := make(map[string]bool, len(m.Requests))
+	m.total = 0
+	for i := range m.Requests {
+		r := &m.Requests[i]
+		if err := r.validate(i); err != nil {
+			return err
+		}
+		if names[r.Name] {
+			return fmt.Errorf("the name of request %d '%s' is duplicated", i+1, r.Name)
+		}
+		names[r.Name] = true
+		// the requests are not in a sequence, so there is nothing to delay them after
+		if r.Delay != 0 {
+			return fmt.Errorf("the delay of request '%s' is not supported by the mix, use the think time instead", r.Name)
+		}
+		r.weight = 1
+		if r.Weight != nil {
+			r.weight = *r.Weight
+		}
+		if r.weight < 0 {
+			return fmt.Errorf("the weight of request '%s' must not be negative", r.Name)
+		}
+		m.total += r.weight
+	}
+	if m.total == 0 {
+		return fmt.Errorf("at least 1 request of the mix must have a positive weight")
+	}
+	return nil
+}
+
+// Names returns the names of the requests in order
+func (m *Mix) Names() []string {
+	names := make([]string, len(m.Requests))
+	for i, r := range m.Requests {
+		names[i] = r.Name
+	}
+	return names
+}
+
+// Share returns the expected share of the i-th request in percent
+func (m *Mix) Share(i int) float64 {
+	return float64(m.Requests[i].weight) * 100 / float64(m.total)
+}
+
+// pick returns a request at random by the weights
+func (m *Mix) pick(w *Worker) *WeightedRequest {
+	n := w.rnd.Intn(m.total)
+	for i := range m.Requests {
+		n -= m.Requests[i].weight
+		if n < 0 {
+			return &m.Requests[i]
+		}
+	}
+	return &m.Requests[len(m.Requests)-1]
+}
+
+// doMixRequest sends a request of the mix picked by the weights, its summary is grouped by the name of the request
+func (w *Worker) doMixRequest(summaryChannel chan Summary, summary Summary) {
+	r := w.TaskDef.Mix.pick(w)
+	summary.Endpoint = r.Name
+	summary, resp := w.send(request{
+		method:  r.Method,
+		url:     r.URL,
+		headers: r.Headers,
+		body:    r.Body,
+		timeout: r.Timeout,
+	}, summary)
+	if resp != nil {
+		w.verify(r.assertions, *resp, &summary)
+		if summary.Success {
+			w.extract(r.Extract, *resp, &summary)
+		}
+	}
+	summaryChannel <- summary
+}
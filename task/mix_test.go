@@ -0,0 +1,120 @@
+package task
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMix(t *testing.T) {
+	ast := assert.New(t)
+	m, err := ParseMix([]byte(`
+name: users
+requests:
+  - name: list
+    weight: 7
+    url: http://localhost:1234/users
+  - name: get
+    weight: 2
+    url: http://localhost:1234/users/{{randInt 1 10}}
+    assert: {status: [200, 404]}
+  - method: post
+    url: http://localhost:1234/users
+    body: '{"name": "tester"}'
+`))
+	ast.Nil(err)
+	ast.Equal([]string{"list", "get", "3 POST http://localhost:1234/users"}, m.Names())
+	ast.Equal(http.MethodGet, m.Requests[0].Method)
+	ast.Nil(m.Requests[2].Weight)
+	ast.Equal(1, m.Requests[2].weight)
+	ast.Len(m.Requests[1].assertions, 1)
+	ast.InDelta(70, m.Share(0), 0.001)
+	ast.InDelta(10, m.Share(2), 0.001)
+
+	_, err = ParseMix([]byte(`requests: []`))
+	ast.NotNil(err)
+	_, err = ParseMix([]byte(`requests: [{name: a}]`))
+	ast.NotNil(err)
+	_, err = ParseMix([]byte(`requests: [{name: a, url: "http://localhost/", weight: -1}]`))
+	ast.NotNil(err)
+	_, err = ParseMix([]byte(`requests: [{name: a, url: "http://localhost/"}, {name: a, url: "http://localhost/"}]`))
+	ast.NotNil(err)
+	_, err = ParseMix([]byte(`requests: [{name: a, url: "http://localhost/", weight: 0}]`))
+	ast.NotNil(err)
+	_, err = ParseMix([]byte(`requests: [{name: a, url: "http://localhost/", delay: 1s}]`))
+	ast.NotNil(err)
+}
+
+func TestPickByWeights(t *testing.T) {
+	ast := assert.New(t)
+	weights := []int{70, 20, 10, 0}
+	m := &Mix{Requests: []WeightedRequest{
+		{Step: Step{Name: "list", URL: "/users"}, Weight: &weights[0]},
+		{Step: Step{Name: "get", URL: "/users/1"}, Weight: &weights[1]},
+		{Step: Step{Name: "create", URL: "/users"}, Weight: &weights[2]},
+		{Step: Step{Name: "never", URL: "/users"}, Weight: &weights[3]},
+	}}
+	ast.Nil(m.Validate())
+	picks := func(seed int64) []string {
+		w := &Worker{rnd: rand.New(rand.NewSource(seed))}
+		names := make([]string, 10000)
+		for i := range names {
+			names[i] = m.pick(w).Name
+		}
+		return names
+	}
+	counts := make(map[string]int)
+	for _, name := range picks(1) {
+		counts[name]++
+	}
+	ast.InDelta(7000, counts["list"], 300)
+	ast.InDelta(2000, counts["get"], 300)
+	ast.InDelta(1000, counts["create"], 300)
+	// a weight of 0 disables the request
+	ast.Zero(counts["never"])
+	ast.Zero(m.Share(3))
+	// the same seed picks the same requests
+	ast.Equal(picks(42), picks(42))
+	ast.NotEqual(picks(42), picks(43))
+}
+
+func TestPlanWithMix(t *testing.T) {
+	ast := assert.New(t)
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Method+" "+r.URL.Path]++
+		mu.Unlock()
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	m, err := ParseMix([]byte(`
+requests:
+  - {name: list, weight: 3, url: ` + server.URL + `/users}
+  - {name: create, weight: 1, method: post, url: ` + server.URL + `/users, assert: {status: [201]}}
+`))
+	ast.Nil(err)
+	run := func() map[string]int {
+		requests = make(map[string]int)
+		plan := &Plan{TaskDef: TaskDef{Loop: 50, Concurrency: 2, Mix: m, Seed: 7, DisableBar: true, DisableReport: true}}
+		plan.Start()
+		ast.Equal(100, plan.Result().TotalCount())
+		ast.Equal(requests["POST /users"], plan.Result().failedCount)
+		grouped := plan.listener.(*GroupedListener)
+		ast.Equal([]string{"list", "create"}, grouped.names)
+		ast.Equal(requests["GET /users"], grouped.groups["list"].TotalCount())
+		ast.Equal(requests["POST /users"], grouped.groups["create"].TotalCount())
+		ast.Equal(requests["POST /users"], grouped.groups["create"].failedCount)
+		return requests
+	}
+	first := run()
+	ast.Greater(first["GET /users"], first["POST /users"])
+	ast.Equal(first, run())
+}
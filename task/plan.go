@@ -1,8 +1,10 @@
 package task
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"math/rand"
 	_ "net/http/pprof"
 	"sync"
 	"time"
@@ -19,13 +21,36 @@ type Plan struct {
 	// barChannel chan int
 	listener Listener
 	report   Report
+	// start is when the workers are started
+	start time.Time
+	// deadline is when a duration-based plan ends, it is zero otherwise
+	deadline time.Time
+	// result is the listener of the whole plan, without the groups
+	result *SimpleListener
+	// windows feeds the latency back to the adaptive scheduler
+	windows         chan LatencyWindow
+	adaptiveHistory *AdaptiveHistory
+	interrupted     bool
+	// budget is the number of requests left, shared by all the workers if TaskDef.Requests is set
+	budget int64
+	// seq is the sequence of the templates
+	seq int64
 }
 
 func (p *Plan) Start() {
+	p.StartContext(context.Background())
+}
+
+// StartContext runs the plan until it is finished or the ctx is done, in the latter case no more requests are sent,
+// the in-flight ones are waited for, and the report is marked as partial
+func (p *Plan) StartContext(interrupt context.Context) {
 	// log.Println(p.TaskDef.TimeUnit)
 	// return
-	listener := BuildSimpleListener(p.TaskDef.Concurrency*p.TaskDef.Loop, p.TaskDef.TimeUnit)
-	p.listener = &listener
+	if len(p.TaskDef.Stages) > 0 {
+		p.TaskDef.Duration = StagesDuration(p.TaskDef.Stages)
+	}
+	p.budget = int64(p.TaskDef.Requests)
+	p.listener = p.buildListener()
 	// p.Assertions = []Assertion{
 	// 	&StatusCodeAssertion{
 	// 		ExpectedCodes: []int{200},
@@ -36,20 +61,37 @@ func (p *Plan) Start() {
 	// p.workerStopChannel = make(chan int, 1024)
 	// p.barChannel = make(chan int, 1024)
 	wg := new(sync.WaitGroup)
+	// workerWG tracks the workers and their pending assertions, the summaryChannel is closed once they are all done
+	workerWG := new(sync.WaitGroup)
+	ctx := interrupt
+	if p.TaskDef.Duration > 0 {
+		var cancel context.CancelFunc
+		p.deadline = time.Now().Add(p.TaskDef.Duration)
+		ctx, cancel = context.WithDeadline(ctx, p.deadline)
+		defer cancel()
+	}
+	if p.TaskDef.Data != nil && p.TaskDef.Data.OnExhausted == ExhaustedStop {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		p.TaskDef.Data.exhausted = cancel
+	}
 	wg.Add(1)
-	go p.startListener(wg, summaryChannel, barChannel)
-	for i := 0; i < p.TaskDef.Concurrency; i++ {
-		wg.Add(1)
-		w := &Worker{
-			ID:      i,
-			TaskDef: p.TaskDef,
-			// WG:             p.wg,
-			// SummaryChannel: summaryChannel,
-			// WorkerStopChannel: p.workerStopChannel,
-			Assertions: p.Assertions,
-		}
-		go w.StartLoop(wg, summaryChannel)
+	go p.startListener(interrupt, wg, summaryChannel, barChannel)
+	p.start = time.Now()
+	if p.TaskDef.Rate > 0 {
+		p.startArrivalRate(ctx, workerWG, summaryChannel)
+	} else if len(p.TaskDef.Stages) > 0 {
+		p.startStages(ctx, workerWG, summaryChannel)
+	} else if p.TaskDef.Adaptive.Enabled() {
+		p.startAdaptive(ctx, workerWG, summaryChannel)
+	} else {
+		p.startWorkers(ctx, workerWG, summaryChannel)
 	}
+	go func() {
+		workerWG.Wait()
+		close(summaryChannel)
+	}()
 	// log.Println("all workers started")
 	// go log.Fatal(http.ListenAndServe(":8001", nil))
 
@@ -61,15 +103,133 @@ func (p *Plan) Start() {
 	wg.Wait()
 }
 
-func (p *Plan) startListener(wg *sync.WaitGroup, summaryChannel chan Summary, barChannel chan int) {
+// Interrupted reports whether the plan was stopped before it finished, so that its report is partial
+func (p *Plan) Interrupted() bool {
+	return p.interrupted
+}
+
+// Result returns the statistics of the whole plan once Start returns
+func (p *Plan) Result() *SimpleListener {
+	return p.result
+}
+
+// newSimpleListener creates a listener with the report options of the plan
+func (p *Plan) newSimpleListener(capacity int) SimpleListener {
+	l := BuildSimpleListener(capacity, p.TaskDef.TimeUnit)
+	if len(p.TaskDef.Percentiles) > 0 {
+		l.percentiles = p.TaskDef.Percentiles
+	}
+	if !p.TaskDef.ExactLatency {
+		precision := p.TaskDef.HistogramPrecision
+		if precision == 0 {
+			precision = DefaultHistogramPrecision
+		}
+		l.UseHistogram(precision)
+	}
+	return l
+}
+
+// buildListener chains the listeners: the stages or the simple one at the bottom, then the warm-up, then the latency windows
+func (p *Plan) buildListener() Listener {
+	simple := p.newSimpleListener(p.expectedCount())
+	simple.openModel = p.TaskDef.Rate > 0
+	simple.reportWorkers = p.TaskDef.Requests > 0 && p.TaskDef.Rate == 0
+	simple.EnableTimeline(p.TaskDef.Interval, p.TaskDef.PrintTimeline)
+	p.result = &simple
+	var listener Listener = &simple
+	if len(p.TaskDef.Stages) > 0 {
+		listener = p.buildStagesListener(listener)
+	}
+	if p.TaskDef.Scenario != nil {
+		listener = p.buildStepsListener(listener)
+	}
+	if p.TaskDef.Mix != nil {
+		listener = p.buildMixListener(listener)
+	}
+	if p.TaskDef.Warmup > 0 || p.TaskDef.WarmupRequests > 0 {
+		warmup := BuildWarmupListener(listener, p.newSimpleListener(p.TaskDef.WarmupRequests), p.TaskDef.Warmup, p.TaskDef.WarmupRequests)
+		warmup.printWarmup = p.TaskDef.PrintWarmup
+		listener = &warmup
+	}
+	if p.TaskDef.Adaptive.Enabled() {
+		p.windows = make(chan LatencyWindow, 16)
+		windowed := BuildWindowListener(listener, p.TaskDef.Adaptive.Window, p.TaskDef.Adaptive.Percentile, p.windows)
+		listener = &windowed
+	}
+	return listener
+}
+
+func (p *Plan) buildStagesListener(main Listener) Listener {
+	stages := p.TaskDef.Stages
+	initial := p.TaskDef.Concurrency
+	names := make([]string, len(stages))
+	for i := range stages {
+		names[i] = stageName(stages, initial, i)
+	}
+	grouped := BuildGroupedListener(main, func() SimpleListener { return p.newSimpleListener(0) }, func(summary Summary) string {
+		i, _ := stageAt(stages, initial, summary.StartTime.Sub(p.start))
+		return names[i]
+	})
+	grouped.Declare(names...)
+	return &grouped
+}
+
+// buildStepsListener reports each step of the scenario apart, the main listener only gets the iterations
+func (p *Plan) buildStepsListener(main Listener) Listener {
+	grouped := BuildGroupedListener(main, func() SimpleListener { return p.newSimpleListener(0) }, func(summary Summary) string {
+		return summary.Step
+	})
+	grouped.exclusive = true
+	grouped.Declare(p.TaskDef.Scenario.StepNames()...)
+	return &grouped
+}
+
+// buildMixListener reports each request of the mix apart, besides all of them in the main listener
+func (p *Plan) buildMixListener(main Listener) Listener {
+	grouped := BuildGroupedListener(main, func() SimpleListener { return p.newSimpleListener(0) }, func(summary Summary) string {
+		return summary.Endpoint
+	})
+	grouped.Declare(p.TaskDef.Mix.Names()...)
+	return &grouped
+}
+
+// startWorkers starts Concurrency workers, each of them sends its next request only after the previous one returns
+func (p *Plan) startWorkers(ctx context.Context, wg *sync.WaitGroup, summaryChannel chan Summary) {
+	for i := 0; i < p.TaskDef.Concurrency; i++ {
+		wg.Add(1)
+		w := p.newWorker(i)
+		go w.StartLoop(ctx, wg, summaryChannel)
+	}
+}
+
+func (p *Plan) newWorker(id int) *Worker {
+	w := &Worker{
+		ID:      id,
+		TaskDef: p.TaskDef,
+		// WG:             p.wg,
+		// SummaryChannel: summaryChannel,
+		// WorkerStopChannel: p.workerStopChannel,
+		Assertions: p.Assertions,
+		seq:        &p.seq,
+	}
+	if p.TaskDef.Seed != 0 {
+		w.rnd = rand.New(rand.NewSource(p.TaskDef.Seed + int64(id)))
+	} else {
+		w.rnd = rand.New(rand.NewSource(time.Now().UnixNano() + int64(id)))
+	}
+	if p.TaskDef.Requests > 0 {
+		w.budget = &p.budget
+	}
+	return w
+}
+
+func (p *Plan) startListener(interrupt context.Context, wg *sync.WaitGroup, summaryChannel chan Summary, barChannel chan int) {
 	defer wg.Done()
 	// t0 := time.Now()
 	var readChannelDuration int64
 	p.listener.OnStart()
-	count := p.TaskDef.Concurrency * p.TaskDef.Loop
-	finished := 0
 	// log.Printf("pre select: %d ns\n", time.Now().Sub(t0).Nanoseconds())
-	for finished < count {
+	for {
 		// select {
 		// case workerID := <-p.workerStopChannel:
 		// 	// log.Printf("worker stopped, id: %d", workerID)
@@ -80,12 +240,15 @@ func (p *Plan) startListener(wg *sync.WaitGroup, summaryChannel chan Summary, ba
 		// 		log.Println("listener is nil")
 		// 	}
 		t1 := time.Now()
-		summ := <-summaryChannel
+		summ, ok := <-summaryChannel
 		readChannelDuration += time.Now().Sub(t1).Milliseconds()
+		if !ok {
+			break
+		}
 
-		finished++
 		if p.listener != nil {
-			if !p.TaskDef.DisableBar {
+			// the bar counts the iterations, not the steps of a scenario
+			if !p.TaskDef.DisableBar && p.TaskDef.Duration == 0 && summ.Step == "" {
 				barChannel <- 1
 			}
 			// log.Printf("OnRequestFinished: %+v\n", summ)
@@ -96,13 +259,27 @@ func (p *Plan) startListener(wg *sync.WaitGroup, summaryChannel chan Summary, ba
 		}
 		// }
 	}
+	if !p.TaskDef.DisableBar {
+		close(barChannel)
+	}
 	// natureDuration := time.Now().Sub(t0).Milliseconds()
+	p.interrupted = interrupt.Err() != nil
+	if p.interrupted {
+		p.result.title = "Conclusion (partial, interrupted)"
+	}
 	p.report = p.listener.OnPlanFinished()
+	if p.TaskDef.DisableReport {
+		return
+	}
 	time.Sleep(101 * time.Millisecond)
 	// log.Printf("natureDuration: %d ms, readChannelDuration: %d ms, %f\n", natureDuration, readChannelDuration, float64(readChannelDuration)/float64(natureDuration))
 	p.TaskDef.PrintToStdOut()
 	// fmt.Printf("%+v", p.report)
 	p.report.PrintToStdOut()
+	if p.adaptiveHistory != nil {
+		fmt.Println()
+		p.adaptiveHistory.PrintToStdOut()
+	}
 }
 
 func (p *Plan) startBar(wg *sync.WaitGroup, barChannel chan int) {
@@ -110,7 +287,11 @@ func (p *Plan) startBar(wg *sync.WaitGroup, barChannel chan int) {
 	if p.TaskDef.DisableBar {
 		return
 	}
-	count := p.TaskDef.Loop * p.TaskDef.Concurrency
+	if p.TaskDef.Duration > 0 {
+		p.startTimeBar(barChannel)
+		return
+	}
+	count := p.expectedCount()
 
 	// bar := pb.New(count).SetMaxWidth(100)
 	// bar.SetRefreshRate(100 * time.Millisecond)
@@ -127,7 +308,43 @@ func (p *Plan) startBar(wg *sync.WaitGroup, barChannel chan int) {
 
 	// bar.Finish()
 
-	bar := progressbar.NewOptions(count,
+	bar := newBar(count)
+	for step := range barChannel {
+		bar.Add(step)
+	}
+	fmt.Println()
+}
+
+// startTimeBar shows the elapsed seconds of a duration-based plan, it returns when the barChannel is closed
+func (p *Plan) startTimeBar(barChannel chan int) {
+	seconds := int(p.TaskDef.Duration / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	bar := newBar(seconds)
+	start := time.Now()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case _, ok := <-barChannel:
+			if !ok {
+				bar.Set(seconds)
+				fmt.Println()
+				return
+			}
+		case <-ticker.C:
+			elapsed := int(time.Since(start) / time.Second)
+			if elapsed > seconds {
+				elapsed = seconds
+			}
+			bar.Set(elapsed)
+		}
+	}
+}
+
+func newBar(max int) *progressbar.ProgressBar {
+	return progressbar.NewOptions(max,
 		progressbar.OptionEnableColorCodes(true),
 		progressbar.OptionSetWidth(30),
 		// progressbar.OptionSetDescription("[cyan]Testing...[reset] "),
@@ -139,11 +356,15 @@ func (p *Plan) startBar(wg *sync.WaitGroup, barChannel chan int) {
 			BarStart:      "[",
 			BarEnd:        "]",
 		}))
-	for i := 0; i < count; i++ {
-		select {
-		case step := <-barChannel:
-			bar.Add(step)
-		}
+}
+
+// expectedCount returns how many requests the plan is going to send, or 0 if it is unknown in advance
+func (p *Plan) expectedCount() int {
+	if p.TaskDef.Duration > 0 {
+		return 0
 	}
-	fmt.Println()
+	if p.TaskDef.Requests > 0 {
+		return p.TaskDef.Requests
+	}
+	return p.TaskDef.Concurrency * p.TaskDef.Loop
 }
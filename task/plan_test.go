@@ -0,0 +1,98 @@
+package task
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanWithDuration(t *testing.T) {
+	ast := assert.New(t)
+	var handled int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt64(&handled, 1)
+	}))
+	defer server.Close()
+	plan := &Plan{TaskDef: TaskDef{Duration: 300 * time.Millisecond, Loop: 1, Concurrency: 4, URL: server.URL, Method: http.MethodGet,
+		DisableBar: true, DisableReport: true}}
+	// the progress of a duration-based plan is the time, the count of requests is unknown
+	ast.Zero(plan.expectedCount())
+	start := time.Now()
+	plan.Start()
+	elapsed := time.Since(start)
+	// the Loop is ignored, and the requests in flight at the deadline are waited for
+	ast.GreaterOrEqual(elapsed, 300*time.Millisecond)
+	ast.Less(elapsed, 500*time.Millisecond)
+	ast.Greater(plan.Result().TotalCount(), 4)
+	ast.Equal(int(atomic.LoadInt64(&handled)), plan.Result().TotalCount())
+	ast.Equal(plan.Result().TotalCount(), plan.Result().successCount)
+	ast.False(plan.Interrupted())
+}
+
+func TestPlanWithRequests(t *testing.T) {
+	ast := assert.New(t)
+	var handled int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&handled, 1)
+	}))
+	defer server.Close()
+	run := func(requests int, concurrency int, data *Feeder) *SimpleListener {
+		atomic.StoreInt64(&handled, 0)
+		plan := &Plan{TaskDef: TaskDef{Requests: requests, Loop: 1, Concurrency: concurrency, URL: server.URL, Method: http.MethodGet,
+			Data: data, DisableBar: true, DisableReport: true}}
+		plan.Start()
+		ast.Equal(int64(requests), atomic.LoadInt64(&handled))
+		return plan.Result()
+	}
+	// the Loop is ignored, and the budget is not divisible by the workers
+	result := run(10, 3, nil)
+	ast.Equal(10, result.TotalCount())
+	sum := 0
+	for _, count := range result.workerCounts {
+		sum += count
+	}
+	ast.Equal(10, sum)
+
+	// 3 of the workers stop early without any record, the last one sends all the requests
+	f := &Feeder{Mode: FeedUnique, OnExhausted: ExhaustedStop, records: []map[string]string{{"id": "1"}}}
+	result = run(7, 4, f)
+	ast.Equal(7, result.TotalCount())
+	ast.Len(result.workerCounts, 1)
+}
+
+func TestInterruptedPlan(t *testing.T) {
+	ast := assert.New(t)
+	var handled int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt64(&handled, 1)
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	plan := &Plan{TaskDef: TaskDef{Loop: 1000, Concurrency: 2, URL: server.URL, Method: http.MethodGet, DisableBar: true}}
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	ast.Nil(err)
+	os.Stdout = w
+	// Start only returns after the summaryChannel is closed and the report is printed
+	plan.StartContext(ctx)
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+
+	ast.True(plan.Interrupted())
+	ast.Less(plan.Result().TotalCount(), 2000)
+	ast.Equal(int(atomic.LoadInt64(&handled)), plan.Result().TotalCount())
+	ast.True(strings.Contains(string(out), "-- Conclusion (partial, interrupted) --"), string(out))
+}
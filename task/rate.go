@@ -0,0 +1,120 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// arrival is a request scheduled by the open-model dispatcher
+type arrival struct {
+	scheduled time.Time
+	// late is true if the request had to wait for a free worker
+	late bool
+}
+
+// ParseRate parses a rate like '500/s', '30/m', '10/100ms' or '500', and returns requests per second
+func ParseRate(rate string) (float64, error) {
+	rate = strings.TrimSpace(rate)
+	if rate == "" {
+		return 0, nil
+	}
+	count, unit, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate '%s': %w", rate, err)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid rate '%s': it must be positive", rate)
+	}
+	if !found {
+		return n, nil
+	}
+	unit = strings.TrimSpace(unit)
+	// allow '/s' as well as '/1s'
+	if unit != "" && (unit[0] < '0' || unit[0] > '9') {
+		unit = "1" + unit
+	}
+	per, err := time.ParseDuration(unit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate '%s': %w", rate, err)
+	}
+	if per <= 0 {
+		return 0, fmt.Errorf("invalid rate '%s': the period must be positive", rate)
+	}
+	return n * float64(time.Second) / float64(per), nil
+}
+
+// startArrivalRate starts a pool of Concurrency workers and schedules the requests on a fixed timeline,
+// a request is sent late if no worker is free at its scheduled time, and dropped if none becomes free before the next one is due
+func (p *Plan) startArrivalRate(ctx context.Context, wg *sync.WaitGroup, summaryChannel chan Summary) {
+	arrivals := make(chan arrival)
+	for i := 0; i < p.TaskDef.Concurrency; i++ {
+		wg.Add(1)
+		w := p.newWorker(i)
+		go w.StartServing(ctx, wg, arrivals, summaryChannel)
+	}
+	wg.Add(1)
+	go p.dispatchArrivals(ctx, wg, arrivals, summaryChannel)
+}
+
+func (p *Plan) dispatchArrivals(ctx context.Context, wg *sync.WaitGroup, arrivals chan arrival, summaryChannel chan Summary) {
+	defer wg.Done()
+	defer close(arrivals)
+	interval := time.Duration(float64(time.Second) / p.TaskDef.Rate)
+	total := p.expectedCount()
+	start := time.Now()
+	if !p.deadline.IsZero() {
+		// the timeline starts with the run, so that it has Duration*Rate requests before the deadline
+		start = p.deadline.Add(-p.TaskDef.Duration)
+	}
+	for i := 0; total == 0 || i < total; i++ {
+		scheduled := start.Add(time.Duration(i) * interval)
+		if !p.deadline.IsZero() && !scheduled.Before(p.deadline) {
+			return
+		}
+		if !sleepUntil(ctx, scheduled) {
+			return
+		}
+		select {
+		case arrivals <- arrival{scheduled: scheduled}:
+			continue
+		default:
+		}
+		// the pool is exhausted, wait for a free worker until the next request is due
+		timer := time.NewTimer(time.Until(scheduled.Add(interval)))
+		select {
+		case arrivals <- arrival{scheduled: scheduled, late: true}:
+			timer.Stop()
+		case <-timer.C:
+			summaryChannel <- droppedAt(scheduled)
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// droppedAt returns the summary of a request scheduled at t which is never sent
+func droppedAt(t time.Time) Summary {
+	return Summary{ScheduledTime: t, StartTime: t, EndTime: t, Dropped: true}
+}
+
+// sleepUntil returns false if the ctx is done before t
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
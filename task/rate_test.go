@@ -0,0 +1,70 @@
+package task
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	ast := assert.New(t)
+	r, err := ParseRate("500/s")
+	ast.Nil(err)
+	ast.Equal(float64(500), r)
+	r, err = ParseRate("30/m")
+	ast.Nil(err)
+	ast.Equal(float64(0.5), r)
+	r, err = ParseRate("10/100ms")
+	ast.Nil(err)
+	ast.Equal(float64(100), r)
+	r, err = ParseRate("200")
+	ast.Nil(err)
+	ast.Equal(float64(200), r)
+	r, err = ParseRate("")
+	ast.Nil(err)
+	ast.Equal(float64(0), r)
+}
+
+func TestParseRateInvalid(t *testing.T) {
+	ast := assert.New(t)
+	_, err := ParseRate("abc/s")
+	ast.NotNil(err)
+	_, err = ParseRate("-1/s")
+	ast.NotNil(err)
+	_, err = ParseRate("10/x")
+	ast.NotNil(err)
+}
+
+func TestArrivalRateWithDuration(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	plan := &Plan{TaskDef: TaskDef{URL: server.URL, Method: http.MethodGet, Concurrency: 4, Loop: 1, Duration: 200 * time.Millisecond,
+		Rate: 100, DisableBar: true, DisableReport: true}}
+	plan.Start()
+	// the Loop is ignored, and the request scheduled at the deadline is not sent
+	result := plan.Result()
+	ast.Equal(20, result.TotalCount()+result.droppedCount)
+}
+
+func TestArrivalRateCountsTheQueueingDelay(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+	// a single worker takes 100ms per request, but a request is due every 90ms,
+	// so the 2nd and the 3rd requests wait for 10ms and 20ms before they are sent
+	plan := &Plan{TaskDef: TaskDef{URL: server.URL, Method: http.MethodGet, Concurrency: 1, Requests: 3,
+		Rate: float64(time.Second) / float64(90*time.Millisecond), DisableBar: true, DisableReport: true}}
+	plan.Start()
+	result := plan.Result()
+	ast.Equal(3, result.TotalCount())
+	ast.Equal(2, result.lateCount)
+	ast.Zero(result.droppedCount)
+	// the 3rd request is scheduled at 180ms, and it ends after the 3 requests which take 300ms at least
+	ast.GreaterOrEqual(result.Percentile(99), 119*time.Millisecond)
+}
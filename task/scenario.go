@@ -0,0 +1,189 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is the ordered steps a virtual user runs in each iteration, e.g. login, create an order, fetch it and delete it
+type Scenario struct {
+	Name  string `yaml:"name,omitempty"`
+	Steps []Step `yaml:"steps"`
+}
+
+// Step is a single request of a scenario, an iteration stops at the first step which fails
+type Step struct {
+	// Name is the group of the step in the report, it is generated from the method and the URL if empty
+	Name    string   `yaml:"name,omitempty"`
+	Method  string   `yaml:"method,omitempty"`
+	URL     string   `yaml:"url"`
+	Headers []string `yaml:"headers,omitempty"`
+	Body    string   `yaml:"body,omitempty"`
+	// Timeout overrides the timeout of the plan if it is set
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Delay is the pause before the step, e.g. the time a user spent on the previous page, it is not counted in the latency
+	Delay  time.Duration  `yaml:"delay,omitempty"`
+	Assert StepAssertions `yaml:"assert,omitempty"`
+	// Extract captures values of the response into variables, which are referenced like '${name}' in the URL, headers and body of the steps
+	Extract []Extractor `yaml:"extract,omitempty"`
+	// assertions are built from Assert by Validate
+	assertions []Assertion
+}
+
+// StepAssertions are the assertions of a step, in the same form as the assertion flags
+type StepAssertions struct {
+	StatusCodes []int  `yaml:"status,omitempty"`
+	JSON        string `yaml:"json,omitempty"`
+	Regex       string `yaml:"regex,omitempty"`
+}
+
+// LoadScenario reads a scenario from a YAML or JSON file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario parses a scenario in YAML or JSON, and validates it
+func ParseScenario(data []byte) (*Scenario, error) {
+	s := &Scenario{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate checks the steps, fills their default names and methods, and builds their assertions
+func (s *Scenario) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("at least 1 step is required by the scenario")
+	}
+	names := make(map[string]bool, len(s.Steps))
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if err := step.validate(i); err != nil {
+			return err
+		}
+		if names[step.Name] {
+			return fmt.Errorf("the name of step %d '%s' is duplicated", i+1, step.Name)
+		}
+		names[step.Name] = true
+	}
+	return nil
+}
+
+// validate checks the i-th step, fills its default name and method, and builds its assertions
+func (step *Step) validate(i int) error {
+	if step.URL == "" {
+		return fmt.Errorf("the url of step %d is required", i+1)
+	}
+	if step.Method == "" {
+		step.Method = http.MethodGet
+	}
+	step.Method = strings.ToUpper(step.Method)
+	if step.Name == "" {
+		step.Name = fmt.Sprintf("%d %s %s", i+1, step.Method, step.URL)
+	}
+	assertions, err := step.Assert.build()
+	if err != nil {
+		return fmt.Errorf("invalid assertion of step '%s': %w", step.Name, err)
+	}
+	step.assertions = assertions
+	for _, value := range append([]string{step.URL, step.Body}, step.Headers...) {
+		if err := ValidateTemplate(value); err != nil {
+			return fmt.Errorf("invalid step '%s': %w", step.Name, err)
+		}
+	}
+	for j := range step.Extract {
+		if err := step.Extract[j].Validate(); err != nil {
+			return fmt.Errorf("invalid step '%s': %w", step.Name, err)
+		}
+	}
+	return nil
+}
+
+func (a StepAssertions) build() ([]Assertion, error) {
+	assertions := make([]Assertion, 0, 3)
+	if len(a.StatusCodes) > 0 {
+		assertions = append(assertions, &StatusCodeAssertion{ExpectedCodes: a.StatusCodes})
+	}
+	if a.JSON != "" {
+		assertions = append(assertions, &JsonPathAssertion{Expression: a.JSON})
+	}
+	if a.Regex != "" {
+		assertions = append(assertions, &RegexAssertion{Expression: a.Regex})
+	}
+	for _, assertion := range assertions {
+		if err := assertion.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return assertions, nil
+}
+
+// StepNames returns the names of the steps in order
+func (s *Scenario) StepNames() []string {
+	names := make([]string, len(s.Steps))
+	for i, step := range s.Steps {
+		names[i] = step.Name
+	}
+	return names
+}
+
+// runScenario runs the steps in order and sends a summary for each of them, followed by the summary of the whole iteration,
+// whose Step is empty. the iteration stops at the first step which fails, and it fails as well.
+// the delays of the steps are not counted in the latency of the iteration, and it is dropped if the ctx is done during a delay
+func (w *Worker) runScenario(ctx context.Context, summaryChannel chan Summary, iteration Summary) {
+	iteration.WorkerID = w.ID
+	iteration.StartTime = time.Now()
+	iteration.Success = true
+	var paused time.Duration
+	for _, step := range w.TaskDef.Scenario.Steps {
+		if step.Delay > 0 {
+			t0 := time.Now()
+			if !sleepUntil(ctx, t0.Add(step.Delay)) {
+				return
+			}
+			paused += time.Since(t0)
+		}
+		summary, resp := w.send(request{
+			method:  step.Method,
+			url:     step.URL,
+			headers: step.Headers,
+			body:    step.Body,
+			timeout: step.Timeout,
+		}, Summary{Step: step.Name})
+		if resp != nil {
+			w.verify(step.assertions, *resp, &summary)
+			if summary.Success {
+				w.extract(step.Extract, *resp, &summary)
+			}
+		}
+		summaryChannel <- summary
+		if summary.HasError || !summary.Success {
+			iteration.HasError = summary.HasError
+			iteration.Success = false
+			iteration.FailedAssertion = summary.FailedAssertion
+			iteration.FailedCause = fmt.Sprintf("%s: %s", step.Name, summary.FailedCause)
+			break
+		}
+	}
+	iteration.StartTime = iteration.StartTime.Add(paused)
+	if !iteration.ScheduledTime.IsZero() {
+		iteration.ScheduledTime = iteration.ScheduledTime.Add(paused)
+	}
+	iteration.EndTime = time.Now()
+	iteration.TimeToLastByte = iteration.EndTime.Sub(iteration.StartTime)
+	summaryChannel <- iteration
+}
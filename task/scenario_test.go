@@ -0,0 +1,138 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScenario(t *testing.T) {
+	ast := assert.New(t)
+	s, err := ParseScenario([]byte(`
+name: orders
+steps:
+  - name: login
+    method: post
+    url: http://localhost/login
+    headers:
+      - "Content-Type: application/json"
+    body: '{"user": "tester"}'
+    timeout: 2s
+    assert:
+      status: [200, 201]
+      json: "$.code == 0"
+  - url: http://localhost/orders
+`))
+	ast.Nil(err)
+	ast.Equal("orders", s.Name)
+	ast.Len(s.Steps, 2)
+	ast.Equal(http.MethodPost, s.Steps[0].Method)
+	ast.Equal(2*time.Second, s.Steps[0].Timeout)
+	ast.Equal([]int{200, 201}, s.Steps[0].Assert.StatusCodes)
+	ast.Len(s.Steps[0].assertions, 2)
+	ast.Equal("2 GET http://localhost/orders", s.Steps[1].Name)
+	ast.Equal([]string{"login", "2 GET http://localhost/orders"}, s.StepNames())
+
+	// json is yaml as well
+	s, err = ParseScenario([]byte(`{"steps": [{"url": "http://localhost/"}]}`))
+	ast.Nil(err)
+	ast.Len(s.Steps, 1)
+
+	_, err = ParseScenario([]byte(`steps: []`))
+	ast.NotNil(err)
+	_, err = ParseScenario([]byte(`steps: [{name: a}]`))
+	ast.NotNil(err)
+	_, err = ParseScenario([]byte(`steps: [{name: a, url: "http://localhost/"}, {name: a, url: "http://localhost/"}]`))
+	ast.NotNil(err)
+	_, err = ParseScenario([]byte(`steps: [{url: "http://localhost/", assert: {json: "$.code"}}]`))
+	ast.NotNil(err)
+	_, err = ParseScenario([]byte(`steps: [{url: "http://localhost/", body: "{{randInt 1}"}]`))
+	ast.NotNil(err)
+}
+
+func TestRunScenario(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(`{"code": 0}`))
+	}))
+	defer server.Close()
+	s, err := ParseScenario([]byte(`
+steps:
+  - name: first
+    url: ` + server.URL + `/first
+    assert: {status: [200], json: "$.code == 0"}
+  - name: missing
+    url: ` + server.URL + `/missing
+    assert: {status: [200]}
+  - name: never
+    url: ` + server.URL + `/never
+`))
+	ast.Nil(err)
+	w := &Worker{ID: 3, TaskDef: TaskDef{Scenario: s}}
+	w.initClient()
+	summaryChannel := make(chan Summary, 8)
+	w.runScenario(context.Background(), summaryChannel, Summary{})
+	close(summaryChannel)
+	summaries := make([]Summary, 0, 3)
+	for summary := range summaryChannel {
+		summaries = append(summaries, summary)
+	}
+	// the iteration stops at the failed step, and its summary comes last
+	ast.Len(summaries, 3)
+	ast.Equal("first", summaries[0].Step)
+	ast.True(summaries[0].Success)
+	ast.Equal("missing", summaries[1].Step)
+	ast.False(summaries[1].Success)
+	ast.Equal("StatusCodeAssertion", summaries[1].FailedAssertion)
+	iteration := summaries[2]
+	ast.Equal("", iteration.Step)
+	ast.False(iteration.Success)
+	ast.Equal(3, iteration.WorkerID)
+	ast.Contains(iteration.FailedCause, "missing")
+	ast.False(iteration.EndTime.Before(summaries[1].EndTime))
+
+	plan := &Plan{TaskDef: TaskDef{Loop: 5, Concurrency: 2, Scenario: s, DisableBar: true, DisableReport: true}}
+	plan.Start()
+	ast.Equal(10, plan.Result().TotalCount())
+	ast.Equal(10, plan.Result().failedCount)
+}
+
+func TestScenarioDelays(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	s, err := ParseScenario([]byte(`
+steps:
+  - {name: first, url: ` + server.URL + `}
+  - {name: second, url: ` + server.URL + `, delay: 100ms}
+`))
+	ast.Nil(err)
+	ast.Equal(100*time.Millisecond, s.Steps[1].Delay)
+	w := &Worker{TaskDef: TaskDef{Scenario: s}}
+	w.initClient()
+	summaryChannel := make(chan Summary, 8)
+	start := time.Now()
+	w.runScenario(context.Background(), summaryChannel, Summary{})
+	ast.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+	ast.Len(summaryChannel, 3)
+	<-summaryChannel
+	<-summaryChannel
+	iteration := <-summaryChannel
+	// the delay is not counted in the latency
+	ast.True(iteration.Success)
+	ast.Less(iteration.EndTime.Sub(iteration.StartTime), 100*time.Millisecond)
+
+	// the iteration is dropped if the run stops during a delay
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	w.runScenario(ctx, summaryChannel, Summary{})
+	ast.Len(summaryChannel, 1)
+	ast.Equal("first", (<-summaryChannel).Step)
+}
@@ -0,0 +1,145 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stage ramps the number of workers linearly to Target within Duration
+type Stage struct {
+	Duration time.Duration `mapstructure:"duration"`
+	Target   int           `mapstructure:"target"`
+}
+
+// ParseStages parses stages like '2m:500,10m:500,2m:0', each of them is 'duration:target'
+func ParseStages(stages string) ([]Stage, error) {
+	result := make([]Stage, 0, 4)
+	for _, item := range strings.Split(stages, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		d, t, found := strings.Cut(item, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid stage '%s', it should be 'duration:target'", item)
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, fmt.Errorf("invalid stage '%s': %w", item, err)
+		}
+		target, err := strconv.Atoi(strings.TrimSpace(t))
+		if err != nil {
+			return nil, fmt.Errorf("invalid stage '%s': %w", item, err)
+		}
+		result = append(result, Stage{Duration: duration, Target: target})
+	}
+	return result, ValidateStages(result)
+}
+
+func ValidateStages(stages []Stage) error {
+	for i, stage := range stages {
+		if stage.Duration <= 0 {
+			return fmt.Errorf("the duration of stage %d must be positive", i+1)
+		}
+		if stage.Target < 0 {
+			return fmt.Errorf("the target of stage %d must not be negative", i+1)
+		}
+	}
+	return nil
+}
+
+// StagesDuration returns the total duration of the stages
+func StagesDuration(stages []Stage) time.Duration {
+	var total time.Duration
+	for _, stage := range stages {
+		total += stage.Duration
+	}
+	return total
+}
+
+// stageAt returns the index of the stage at the elapsed time and the number of workers it expects,
+// the number ramps linearly from the target of the previous stage, or from the initial number for the first stage
+func stageAt(stages []Stage, initial int, elapsed time.Duration) (int, int) {
+	from := initial
+	var begin time.Duration
+	for i, stage := range stages {
+		if elapsed < begin+stage.Duration {
+			progress := float64(elapsed-begin) / float64(stage.Duration)
+			return i, from + int(math.Round(float64(stage.Target-from)*progress))
+		}
+		begin += stage.Duration
+		from = stage.Target
+	}
+	return len(stages) - 1, from
+}
+
+// stageName describes the i-th stage for the report
+func stageName(stages []Stage, initial int, i int) string {
+	from := initial
+	if i > 0 {
+		from = stages[i-1].Target
+	}
+	return fmt.Sprintf("Stage %d (%s, %d -> %d workers)", i+1, stages[i].Duration, from, stages[i].Target)
+}
+
+// workerPool starts and stops closed-model workers on demand, the most recently started worker is stopped first
+type workerPool struct {
+	plan           *Plan
+	ctx            context.Context
+	wg             *sync.WaitGroup
+	summaryChannel chan Summary
+	cancels        []context.CancelFunc
+	nextID         int
+}
+
+func (wp *workerPool) size() int {
+	return len(wp.cancels)
+}
+
+// scale starts or stops workers until there are n running, a stopped worker finishes its current request first
+func (wp *workerPool) scale(n int) {
+	for len(wp.cancels) < n {
+		ctx, cancel := context.WithCancel(wp.ctx)
+		wp.cancels = append(wp.cancels, cancel)
+		wp.wg.Add(1)
+		w := wp.plan.newWorker(wp.nextID)
+		wp.nextID++
+		go w.StartLoop(ctx, wp.wg, wp.summaryChannel)
+	}
+	for len(wp.cancels) > n {
+		last := len(wp.cancels) - 1
+		wp.cancels[last]()
+		wp.cancels = wp.cancels[:last]
+	}
+}
+
+// startStages starts Concurrency workers and then follows the stages by starting or stopping workers over time
+func (p *Plan) startStages(ctx context.Context, wg *sync.WaitGroup, summaryChannel chan Summary) {
+	pool := &workerPool{
+		plan:           p,
+		ctx:            ctx,
+		wg:             wg,
+		summaryChannel: summaryChannel,
+	}
+	pool.scale(p.TaskDef.Concurrency)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, target := stageAt(p.TaskDef.Stages, p.TaskDef.Concurrency, time.Since(p.start))
+				pool.scale(target)
+			}
+		}
+	}()
+}
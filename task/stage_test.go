@@ -0,0 +1,51 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStages(t *testing.T) {
+	ast := assert.New(t)
+	stages, err := ParseStages("2m:500, 10m:500,2m:0")
+	ast.Nil(err)
+	ast.Equal([]Stage{
+		{Duration: 2 * time.Minute, Target: 500},
+		{Duration: 10 * time.Minute, Target: 500},
+		{Duration: 2 * time.Minute, Target: 0},
+	}, stages)
+	ast.Equal(14*time.Minute, StagesDuration(stages))
+
+	_, err = ParseStages("2m")
+	ast.NotNil(err)
+	_, err = ParseStages("0s:10")
+	ast.NotNil(err)
+	_, err = ParseStages("1m:-1")
+	ast.NotNil(err)
+}
+
+func TestStageAt(t *testing.T) {
+	ast := assert.New(t)
+	stages := []Stage{
+		{Duration: 2 * time.Minute, Target: 500},
+		{Duration: 10 * time.Minute, Target: 500},
+		{Duration: 2 * time.Minute, Target: 0},
+	}
+	i, target := stageAt(stages, 10, 0)
+	ast.Equal(0, i)
+	ast.Equal(10, target)
+	i, target = stageAt(stages, 10, time.Minute)
+	ast.Equal(0, i)
+	ast.Equal(255, target)
+	i, target = stageAt(stages, 10, 5*time.Minute)
+	ast.Equal(1, i)
+	ast.Equal(500, target)
+	i, target = stageAt(stages, 10, 13*time.Minute)
+	ast.Equal(2, i)
+	ast.Equal(250, target)
+	i, target = stageAt(stages, 10, 20*time.Minute)
+	ast.Equal(2, i)
+	ast.Equal(0, target)
+}
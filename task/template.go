@@ -0,0 +1,160 @@
+package task
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// timeLayouts are the layouts which can be given to 'now' by name, other layouts are used as they are
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"RFC822":      time.RFC822,
+	"RFC1123":     time.RFC1123,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+const randLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// templateFuncs returns the functions of the templates of the worker, a nil worker gives functions which are only good for parsing
+func templateFuncs(w *Worker) template.FuncMap {
+	return template.FuncMap{
+		"uuid": func() string {
+			return uuid.New().String()
+		},
+		// randInt returns a random integer in [min, max]
+		"randInt": func(min, max int) int {
+			if max <= min {
+				return min
+			}
+			return min + w.rnd.Intn(max-min+1)
+		},
+		"randString": func(n int) string {
+			b := make([]byte, n)
+			for i := range b {
+				b[i] = randLetters[w.rnd.Intn(len(randLetters))]
+			}
+			return string(b)
+		},
+		// now formats the current time by the layout, e.g. "RFC3339" or "2006-01-02", RFC3339 by default
+		"now": func(layout ...string) string {
+			l := time.RFC3339
+			if len(layout) > 0 {
+				l = layout[0]
+				if named, ok := timeLayouts[l]; ok {
+					l = named
+				}
+			}
+			return time.Now().Format(l)
+		},
+		"unix": func() int64 {
+			return time.Now().Unix()
+		},
+		"unixMillis": func() int64 {
+			return time.Now().UnixMilli()
+		},
+		// seq is a sequence shared by all the workers of the plan, starting from 1
+		"seq": func() int64 {
+			return atomic.AddInt64(w.seq, 1)
+		},
+		"workerID": func() int {
+			return w.ID
+		},
+		// iteration is the number of the current iteration of the worker, starting from 1
+		"iteration": func() int {
+			return w.iteration
+		},
+		"base64": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"base64URL": func(s string) string {
+			return base64.URLEncoding.EncodeToString([]byte(s))
+		},
+		"sha256": func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		},
+		// hmac returns the hex of the HMAC-SHA256 of the message by the key
+		"hmac": func(key, message string) string {
+			mac := hmac.New(sha256.New, []byte(key))
+			mac.Write([]byte(message))
+			return hex.EncodeToString(mac.Sum(nil))
+		},
+		"env": os.Getenv,
+		// var returns a variable of the virtual user, from the data or the extractors
+		"var": func(name string) string {
+			return w.vars[name]
+		},
+	}
+}
+
+// ValidateTemplate checks the syntax and the functions of a template
+func ValidateTemplate(s string) error {
+	if !strings.Contains(s, "{{") {
+		return nil
+	}
+	if _, err := template.New("").Funcs(templateFuncs(nil)).Parse(s); err != nil {
+		return fmt.Errorf("invalid template '%s': %w", s, err)
+	}
+	return nil
+}
+
+// render executes the template of s if it has one, and then expands the variables in it.
+// the templates are parsed once for each worker, since their functions are bound to it
+func (w *Worker) render(s string) (string, error) {
+	if strings.Contains(s, "{{") {
+		t, ok := w.templates[s]
+		if !ok {
+			if w.templates == nil {
+				w.templates = make(map[string]*template.Template, 8)
+			}
+			if w.seq == nil {
+				w.seq = new(int64)
+			}
+			var err error
+			if t, err = template.New("").Funcs(templateFuncs(w)).Parse(s); err != nil {
+				return "", err
+			}
+			w.templates[s] = t
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, nil); err != nil {
+			return "", err
+		}
+		s = b.String()
+	}
+	return expand(s, w.vars), nil
+}
+
+// renderRequest renders the URL, the headers and the body of the request for this time
+func (w *Worker) renderRequest(r request) (request, error) {
+	var err error
+	if r.url, err = w.render(r.url); err != nil {
+		return r, err
+	}
+	if r.body, err = w.render(r.body); err != nil {
+		return r, err
+	}
+	headers := make([]string, len(r.headers))
+	for i, header := range r.headers {
+		if headers[i], err = w.render(header); err != nil {
+			return r, err
+		}
+	}
+	r.headers = headers
+	return r, nil
+}
@@ -0,0 +1,84 @@
+package task
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	ast := assert.New(t)
+	t.Setenv("HTTPTESTER_TOKEN", "t0k")
+	seq := int64(0)
+	w := &Worker{ID: 3, rnd: rand.New(rand.NewSource(1)), seq: &seq, vars: map[string]string{"id": "42"}}
+	w.iteration = 2
+	render := func(s string) string {
+		result, err := w.render(s)
+		ast.Nil(err, s)
+		return result
+	}
+	ast.Equal("/users/42", render("/users/${id}"))
+	ast.Equal("1 2 3", render("{{seq}} {{seq}} {{workerID}}"))
+	ast.Equal("3", render("{{seq}}"))
+	ast.Equal("2 42 t0k", render(`{{iteration}} {{var "id"}} {{env "HTTPTESTER_TOKEN"}}`))
+	ast.Regexp(regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`), render("{{uuid}}"))
+	ast.Regexp(regexp.MustCompile(`^[A-Za-z0-9]{12}$`), render("{{randString 12}}"))
+	ast.NotEqual(render("{{uuid}}"), render("{{uuid}}"))
+	for i := 0; i < 100; i++ {
+		n := render("{{randInt 1 3}}")
+		ast.Contains([]string{"1", "2", "3"}, n)
+	}
+	_, err := time.Parse(time.RFC3339, render(`{{now "RFC3339"}}`))
+	ast.Nil(err)
+	_, err = time.Parse("2006-01-02", render(`{{now "2006-01-02"}}`))
+	ast.Nil(err)
+	ast.Regexp(regexp.MustCompile(`^\d{13}$`), render("{{unixMillis}}"))
+	ast.Equal("aGVsbG8=", render(`{{base64 "hello"}}`))
+	ast.Equal("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", render(`{{sha256 "hello"}}`))
+	ast.Equal("f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", render(`{{hmac "key" "The quick brown fox jumps over the lazy dog"}}`))
+	ast.Equal("a2V5LTQy", render(`{{base64 (var "id" | printf "key-%s")}}`))
+
+	ast.Nil(ValidateTemplate("/users/${id}"))
+	ast.Nil(ValidateTemplate("{{randInt 1 100}}"))
+	ast.NotNil(ValidateTemplate("{{randInt 1 100"))
+	ast.NotNil(ValidateTemplate("{{unknown}}"))
+}
+
+func TestPlanWithTemplates(t *testing.T) {
+	ast := assert.New(t)
+	var mu sync.Mutex
+	bodies := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies[r.URL.Path+" "+r.Header.Get("X-Worker")+" "+string(body)]++
+		mu.Unlock()
+	}))
+	defer server.Close()
+	plan := &Plan{TaskDef: TaskDef{
+		Loop:          3,
+		Concurrency:   2,
+		URL:           server.URL + "/users/{{iteration}}",
+		Method:        http.MethodPost,
+		Headers:       []string{"X-Worker: {{workerID}}"},
+		Body:          `{"name": "user-{{seq}}"}`,
+		DisableBar:    true,
+		DisableReport: true,
+	}}
+	plan.Start()
+	ast.Equal(6, plan.Result().TotalCount())
+	ast.Len(bodies, 6)
+	names := make(map[string]bool)
+	for key, count := range bodies {
+		ast.Equal(1, count)
+		names[regexp.MustCompile(`user-\d`).FindString(key)] = true
+	}
+	ast.Len(names, 6)
+}
@@ -0,0 +1,129 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// ThinkTime is the pause of a worker between two iterations, like a real user
+type ThinkTime interface {
+	Next(r *rand.Rand) time.Duration
+	String() string
+}
+
+type constantThinkTime struct {
+	d time.Duration
+}
+
+func (t constantThinkTime) Next(r *rand.Rand) time.Duration {
+	return t.d
+}
+
+func (t constantThinkTime) String() string {
+	return "constant:" + t.d.String()
+}
+
+type uniformThinkTime struct {
+	min time.Duration
+	max time.Duration
+}
+
+func (t uniformThinkTime) Next(r *rand.Rand) time.Duration {
+	if t.max <= t.min {
+		return t.min
+	}
+	return t.min + time.Duration(r.Int63n(int64(t.max-t.min)))
+}
+
+func (t uniformThinkTime) String() string {
+	return fmt.Sprintf("uniform:%s,%s", t.min, t.max)
+}
+
+type normalThinkTime struct {
+	mean   time.Duration
+	stdDev time.Duration
+}
+
+func (t normalThinkTime) Next(r *rand.Rand) time.Duration {
+	d := time.Duration(r.NormFloat64()*float64(t.stdDev)) + t.mean
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (t normalThinkTime) String() string {
+	return fmt.Sprintf("normal:%s,%s", t.mean, t.stdDev)
+}
+
+type exponentialThinkTime struct {
+	mean time.Duration
+}
+
+func (t exponentialThinkTime) Next(r *rand.Rand) time.Duration {
+	return time.Duration(r.ExpFloat64() * float64(t.mean))
+}
+
+func (t exponentialThinkTime) String() string {
+	return "exponential:" + t.mean.String()
+}
+
+// ParseThinkTime parses the distribution of the think time:
+// '500ms' or 'constant:500ms', 'uniform:100ms,500ms' for min and max, 'normal:300ms,50ms' for mean and standard deviation, 'exponential:200ms' for mean
+func ParseThinkTime(spec string) (ThinkTime, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	kind, args, found := strings.Cut(spec, ":")
+	if !found {
+		kind, args = "constant", spec
+	}
+	durations := make([]time.Duration, 0, 2)
+	for _, arg := range strings.Split(args, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(arg))
+		if err != nil {
+			return nil, fmt.Errorf("invalid think time '%s': %w", spec, err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("invalid think time '%s': durations must not be negative", spec)
+		}
+		durations = append(durations, d)
+	}
+	expected := map[string]int{"constant": 1, "uniform": 2, "normal": 2, "exponential": 1}
+	n, ok := expected[strings.ToLower(kind)]
+	if !ok {
+		return nil, fmt.Errorf("unknown distribution '%s' of think time, it should be one of constant, uniform, normal and exponential", kind)
+	}
+	if len(durations) != n {
+		return nil, fmt.Errorf("invalid think time '%s': %s expects %d durations", spec, kind, n)
+	}
+	switch strings.ToLower(kind) {
+	case "uniform":
+		if durations[1] < durations[0] {
+			return nil, fmt.Errorf("invalid think time '%s': the max is less than the min", spec)
+		}
+		return uniformThinkTime{min: durations[0], max: durations[1]}, nil
+	case "normal":
+		return normalThinkTime{mean: durations[0], stdDev: durations[1]}, nil
+	case "exponential":
+		return exponentialThinkTime{mean: durations[0]}, nil
+	}
+	return constantThinkTime{d: durations[0]}, nil
+}
+
+// pause waits for the think time and the pacing before the next iteration, it returns false if the ctx is done meanwhile.
+// the next iteration starts no earlier than the think time from now, and no earlier than the pacing from the start of the last one
+func (w *Worker) pause(ctx context.Context, lastStart time.Time) bool {
+	next := time.Now()
+	if w.TaskDef.ThinkTime != nil {
+		next = next.Add(w.TaskDef.ThinkTime.Next(w.rnd))
+	}
+	if paced := lastStart.Add(w.TaskDef.Pacing); w.TaskDef.Pacing > 0 && paced.After(next) {
+		next = paced
+	}
+	return sleepUntil(ctx, next)
+}
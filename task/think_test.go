@@ -0,0 +1,51 @@
+package task
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseThinkTime(t *testing.T) {
+	ast := assert.New(t)
+	r := rand.New(rand.NewSource(1))
+	think, err := ParseThinkTime("500ms")
+	ast.Nil(err)
+	ast.Equal(500*time.Millisecond, think.Next(r))
+	ast.Equal("constant:500ms", think.String())
+
+	think, err = ParseThinkTime("uniform:100ms,200ms")
+	ast.Nil(err)
+	for i := 0; i < 100; i++ {
+		d := think.Next(r)
+		ast.True(d >= 100*time.Millisecond && d < 200*time.Millisecond, d)
+	}
+
+	think, err = ParseThinkTime("normal:300ms,50ms")
+	ast.Nil(err)
+	for i := 0; i < 100; i++ {
+		ast.True(think.Next(r) >= 0)
+	}
+
+	think, err = ParseThinkTime("exponential:200ms")
+	ast.Nil(err)
+	var total time.Duration
+	for i := 0; i < 10000; i++ {
+		total += think.Next(r)
+	}
+	ast.InDelta(float64(200*time.Millisecond), float64(total/10000), float64(20*time.Millisecond))
+
+	think, err = ParseThinkTime("")
+	ast.Nil(err)
+	ast.Nil(think)
+}
+
+func TestParseThinkTimeInvalid(t *testing.T) {
+	ast := assert.New(t)
+	for _, spec := range []string{"abc", "poisson:1s", "uniform:1s", "uniform:2s,1s", "normal:1s,x", "-1s"} {
+		_, err := ParseThinkTime(spec)
+		ast.NotNil(err, spec)
+	}
+}
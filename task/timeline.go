@@ -0,0 +1,83 @@
+package task
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimelineBucket is the statistics of the requests finished within an interval of the run
+type TimelineBucket struct {
+	// Offset is the start of the interval since the start of the run
+	Offset       time.Duration
+	Count        int
+	SuccessCount int
+	FailedCount  int
+	ErrorCount   int
+	P50          time.Duration
+	P95          time.Duration
+	P99          time.Duration
+	latencies    LatencyRecorder
+}
+
+// timelinePrecision is the precision of the histograms of the buckets, it is lower than the one of the whole run since a long run has many buckets.
+// the buckets are histograms in the exact mode as well, so that they do not keep every latency once more
+const timelinePrecision = 2
+
+// timeline groups the requests by the interval in which they finished
+type timeline struct {
+	interval time.Duration
+	buckets  []*TimelineBucket
+}
+
+func (t *timeline) add(start time.Time, summary Summary) {
+	offset := summary.EndTime.Sub(start)
+	if offset < 0 {
+		offset = 0
+	}
+	index := int(offset / t.interval)
+	for len(t.buckets) <= index {
+		t.buckets = append(t.buckets, &TimelineBucket{
+			Offset:    time.Duration(len(t.buckets)) * t.interval,
+			latencies: NewHistogram(timelinePrecision),
+		})
+	}
+	b := t.buckets[index]
+	b.Count++
+	if summary.HasError {
+		b.ErrorCount++
+	} else if summary.Success {
+		b.SuccessCount++
+	} else {
+		b.FailedCount++
+	}
+	b.latencies.Record(summary.Latency().Nanoseconds())
+}
+
+func (t *timeline) calculate() {
+	for _, b := range t.buckets {
+		b.P50 = time.Duration(b.latencies.Percentile(50))
+		b.P95 = time.Duration(b.latencies.Percentile(95))
+		b.P99 = time.Duration(b.latencies.Percentile(99))
+		b.latencies = nil
+	}
+}
+
+// Timeline returns the statistics of each interval, it is only available after OnPlanFinished if the interval is set
+func (s *SimpleListener) Timeline() []TimelineBucket {
+	if s.timeline == nil {
+		return nil
+	}
+	result := make([]TimelineBucket, len(s.timeline.buckets))
+	for i, b := range s.timeline.buckets {
+		result[i] = *b
+	}
+	return result
+}
+
+func (s *SimpleListener) printTimelineTable() {
+	fmt.Println("-- Timeline --")
+	fmt.Printf("time\tcount\tsuccess\tfailed\terror\tp50 (%s)\tp95 (%s)\tp99 (%s)\n", s.timeunit, s.timeunit, s.timeunit)
+	for _, b := range s.Timeline() {
+		fmt.Printf("%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n", b.Offset, b.Count, b.SuccessCount, b.FailedCount, b.ErrorCount, s.format(float64(b.P50)), s.format(float64(b.P95)), s.format(float64(b.P99)))
+	}
+}
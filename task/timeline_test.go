@@ -0,0 +1,45 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeline(t *testing.T) {
+	ast := assert.New(t)
+	l := BuildSimpleListener(0, "ms")
+	l.EnableTimeline(time.Second, false)
+	l.OnStart()
+	start := l.start
+	finish := func(end time.Duration, cost time.Duration, success bool, hasError bool) {
+		l.OnRequestFinished(Summary{
+			StartTime: start.Add(end - cost),
+			EndTime:   start.Add(end),
+			Success:   success,
+			HasError:  hasError,
+		})
+	}
+	for i := 1; i <= 100; i++ {
+		finish(500*time.Millisecond, time.Duration(i)*time.Millisecond, true, false)
+	}
+	// nothing finished in the second interval
+	finish(2500*time.Millisecond, time.Millisecond, false, false)
+	finish(2600*time.Millisecond, time.Millisecond, false, true)
+	l.OnPlanFinished()
+
+	buckets := l.Timeline()
+	ast.Len(buckets, 3)
+	ast.Equal(time.Duration(0), buckets[0].Offset)
+	ast.Equal(100, buckets[0].SuccessCount)
+	// the buckets are histograms even in the exact mode
+	ast.InEpsilon(50*time.Millisecond, buckets[0].P50, 0.01)
+	ast.InEpsilon(95*time.Millisecond, buckets[0].P95, 0.01)
+	ast.InEpsilon(99*time.Millisecond, buckets[0].P99, 0.01)
+	ast.Equal(time.Second, buckets[1].Offset)
+	ast.Equal(0, buckets[1].Count)
+	ast.Equal(2, buckets[2].Count)
+	ast.Equal(1, buckets[2].FailedCount)
+	ast.Equal(1, buckets[2].ErrorCount)
+}
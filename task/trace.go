@@ -0,0 +1,128 @@
+package task
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Phases is the time spent in each phase of a request, a phase is 0 if it did not happen, e.g. DNS on a reused connection
+type Phases struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// TTFB is from the request being written to the first byte of the response, i.e. the time the server took
+	TTFB time.Duration
+	// Download is from the headers to the last byte of the body
+	Download time.Duration
+}
+
+// phaseNames are the rows of the phase table, in the order of the fields of Phases
+var phaseNames = []string{"dns", "connect", "tls", "ttfb", "download"}
+
+func (p Phases) values() []time.Duration {
+	return []time.Duration{p.DNS, p.Connect, p.TLS, p.TTFB, p.Download}
+}
+
+// phaseTrace records the moments of a request by httptrace, the callbacks may come from the goroutine which dials
+type phaseTrace struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func (t *phaseTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	// keep the first one, since a dual-stack dial may try several addresses
+	if at.IsZero() {
+		*at = time.Now()
+	}
+	t.mu.Unlock()
+}
+
+// withTrace returns the request which reports its phases to the trace
+func withTrace(req *http.Request, t *phaseTrace) *http.Request {
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart:      func(string, string) { t.mark(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart: func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.mu.Unlock()
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.mark(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// phases returns the phases and whether the connection was reused
+func (t *phaseTrace) phases() (Phases, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Phases{
+		DNS:     between(t.dnsStart, t.dnsDone),
+		Connect: between(t.connectStart, t.connectDone),
+		TLS:     between(t.tlsStart, t.tlsDone),
+		TTFB:    between(t.wroteRequest, t.firstByte),
+	}, t.reused
+}
+
+func between(start time.Time, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// recordPhases adds the phases which happened to the recorders of the listener
+func (s *SimpleListener) recordPhases(summary Summary) {
+	if summary.ConnReused {
+		s.reusedConns++
+	} else if summary.Phases.Connect > 0 {
+		s.newConns++
+	}
+	for i, d := range summary.Phases.values() {
+		if d <= 0 {
+			continue
+		}
+		if s.phases == nil {
+			s.phases = make([]LatencyRecorder, len(phaseNames))
+			for j := range s.phases {
+				s.phases[j] = newRecorder(s.precision)
+			}
+		}
+		s.phases[i].Record(int64(d))
+	}
+}
+
+// printPhases prints the statistics of each phase of the requests, in the timeunit of the report
+func (s *SimpleListener) printPhases() {
+	if s.phases == nil {
+		return
+	}
+	fmt.Printf("connections: %d new, %d reused\n", s.newConns, s.reusedConns)
+	fmt.Printf("phases (%s):\tcount\tmin\tmean\tp50\tp95\tp99\tmax\n", s.timeunit)
+	for i, r := range s.phases {
+		if r.Count() == 0 {
+			continue
+		}
+		fmt.Printf("  %s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", phaseNames[i], r.Count(),
+			s.format(float64(r.Min())), s.format(r.Mean()), s.format(float64(r.Percentile(50))),
+			s.format(float64(r.Percentile(95))), s.format(float64(r.Percentile(99))), s.format(float64(r.Max())))
+	}
+}
@@ -0,0 +1,79 @@
+package task
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhasesOfRequests(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+	w := &Worker{TaskDef: TaskDef{URL: server.URL, Method: http.MethodGet, Insecure: true}}
+	w.initClient()
+	summaryChannel := make(chan Summary, 2)
+	wg := &sync.WaitGroup{}
+	w.doRequest(wg, summaryChannel, Summary{})
+	first := <-summaryChannel
+	w.doRequest(wg, summaryChannel, Summary{})
+	second := <-summaryChannel
+	wg.Wait()
+
+	ast.True(first.Success)
+	ast.False(first.ConnReused)
+	ast.Greater(first.Phases.Connect, time.Duration(0))
+	ast.Greater(first.Phases.TLS, time.Duration(0))
+	ast.GreaterOrEqual(first.Phases.TTFB, 10*time.Millisecond)
+	ast.True(second.ConnReused)
+	ast.Zero(second.Phases.Connect)
+	ast.Zero(second.Phases.TLS)
+	ast.GreaterOrEqual(second.Phases.TTFB, 10*time.Millisecond)
+
+	l := BuildSimpleListener(0, "ms")
+	l.OnStart()
+	l.OnRequestFinished(first)
+	l.OnRequestFinished(second)
+	l.OnPlanFinished()
+	ast.Equal(1, l.newConns)
+	ast.Equal(1, l.reusedConns)
+	ast.Equal(int64(1), l.phases[1].Count())
+	ast.Equal(int64(1), l.phases[2].Count())
+	ast.Equal(int64(2), l.phases[3].Count())
+}
+
+func TestLatencyIncludesBody(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("last"))
+	}))
+	defer server.Close()
+	for _, mode := range []string{LatencyBody, LatencyHeaders} {
+		w := &Worker{TaskDef: TaskDef{URL: server.URL, Method: http.MethodGet, LatencyMode: mode}}
+		w.initClient()
+		summaryChannel := make(chan Summary, 1)
+		wg := &sync.WaitGroup{}
+		w.doRequest(wg, summaryChannel, Summary{})
+		summary := <-summaryChannel
+		wg.Wait()
+		ast.True(summary.Success)
+		ast.Less(summary.TimeToHeaders, 50*time.Millisecond)
+		ast.GreaterOrEqual(summary.TimeToLastByte, 50*time.Millisecond)
+		ast.Equal(summary.TimeToLastByte-summary.TimeToHeaders, summary.Phases.Download)
+		if mode == LatencyBody {
+			ast.Equal(summary.TimeToLastByte, summary.EndTime.Sub(summary.StartTime))
+		} else {
+			ast.Equal(summary.TimeToHeaders, summary.EndTime.Sub(summary.StartTime))
+		}
+	}
+}
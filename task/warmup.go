@@ -0,0 +1,92 @@
+package task
+
+import (
+	"fmt"
+	"time"
+)
+
+// WarmupListener keeps the requests of the warm-up phase away from the main listener,
+// the phase ends after the duration since OnStart, or after the number of requests, whichever is set
+type WarmupListener struct {
+	main        Listener
+	warmup      SimpleListener
+	duration    time.Duration
+	requests    int
+	printWarmup bool
+	start       time.Time
+	seen        int
+	warming     bool
+}
+
+// BuildWarmupListener creates a WarmupListener, the requests of the warm-up phase are passed to the warmup listener
+func BuildWarmupListener(main Listener, warmup SimpleListener, duration time.Duration, requests int) WarmupListener {
+	warmup.title = "Warm-up (excluded from the statistics)"
+	// the warm-up is finished with the plan, so that the late requests of the phase are counted in it
+	warmup.measureSpan = true
+	return WarmupListener{
+		main:     main,
+		warmup:   warmup,
+		duration: duration,
+		requests: requests,
+	}
+}
+
+func (l *WarmupListener) OnStart() {
+	l.start = time.Now()
+	l.warmup.OnStart()
+	l.warming = true
+}
+
+// OnRequestFinished classifies each request by itself rather than by the order of arrival,
+// since a request started in the warm-up phase may finish after one started after it
+func (l *WarmupListener) OnRequestFinished(summary Summary) {
+	if l.inWarmup(summary) {
+		l.warmup.OnRequestFinished(summary)
+		return
+	}
+	if l.warming {
+		l.endWarmup()
+	}
+	l.main.OnRequestFinished(summary)
+}
+
+func (l *WarmupListener) inWarmup(summary Summary) bool {
+	if l.duration > 0 {
+		return summary.StartTime.Sub(l.start) < l.duration
+	}
+	// the steps of a scenario come before the summary of their iteration, only the iterations are counted
+	if summary.Step != "" {
+		return l.seen < l.requests
+	}
+	l.seen++
+	return l.seen <= l.requests
+}
+
+// endWarmup starts the main listener, so that its nature duration excludes the warm-up as well
+func (l *WarmupListener) endWarmup() {
+	l.warming = false
+	l.main.OnStart()
+}
+
+func (l *WarmupListener) OnPlanFinished() Report {
+	if l.warming {
+		l.endWarmup()
+	}
+	l.warmup.OnPlanFinished()
+	report := l.main.OnPlanFinished()
+	if !l.printWarmup {
+		return report
+	}
+	return warmupReport{main: report, warmup: &l.warmup}
+}
+
+type warmupReport struct {
+	main   Report
+	warmup Report
+}
+
+func (r warmupReport) PrintToStdOut() {
+	r.warmup.PrintToStdOut()
+	fmt.Println()
+	r.main.PrintToStdOut()
+}
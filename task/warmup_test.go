@@ -0,0 +1,37 @@
+package task
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmupListenerByRequests(t *testing.T) {
+	ast := assert.New(t)
+	main := BuildSimpleListener(0, "ms")
+	l := BuildWarmupListener(&main, BuildSimpleListener(0, "ms"), 0, 3)
+	l.OnStart()
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		l.OnRequestFinished(Summary{StartTime: now, EndTime: now.Add(time.Millisecond), Success: true})
+	}
+	l.OnPlanFinished()
+	ast.Equal(3, l.warmup.TotalCount())
+	ast.Equal(7, main.TotalCount())
+}
+
+func TestWarmupListenerByDuration(t *testing.T) {
+	ast := assert.New(t)
+	main := BuildSimpleListener(0, "ms")
+	l := BuildWarmupListener(&main, BuildSimpleListener(0, "ms"), time.Second, 0)
+	l.OnStart()
+	// the request started at 950ms finishes after the one started at 1100ms, it is still in the warm-up
+	for _, offset := range []time.Duration{0, 500 * time.Millisecond, 900 * time.Millisecond, 1100 * time.Millisecond, 950 * time.Millisecond, 2 * time.Second} {
+		start := l.start.Add(offset)
+		l.OnRequestFinished(Summary{StartTime: start, EndTime: start.Add(time.Millisecond), Success: true})
+	}
+	l.OnPlanFinished()
+	ast.Equal(4, l.warmup.TotalCount())
+	ast.Equal(2, main.TotalCount())
+}
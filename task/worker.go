@@ -2,20 +2,60 @@ package task
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 )
 
 type TaskDef struct {
 	Loop        int
 	Concurrency int
+	// Requests is the total number of requests shared by all the workers, a free worker takes the next one, Loop is ignored if it is set
+	Requests int
+	// Duration makes every worker keep sending requests until the deadline, Loop is ignored if it is set
+	Duration time.Duration
+	// Rate is the number of requests per second in the open model, Concurrency becomes the maximum of in-flight requests
+	Rate float64
+	// Stages change the number of workers over time, starting from Concurrency, the Duration is the total of them
+	Stages []Stage
+	// Adaptive changes the number of workers, starting from Concurrency, to keep the latency near a target
+	Adaptive AdaptiveDef
+	// Warmup or WarmupRequests is the beginning of the run which is excluded from the statistics
+	Warmup         time.Duration
+	WarmupRequests int
+	PrintWarmup    bool
+	// Data provides the variables of each iteration if it is set
+	Data *Feeder
+	// Scenario replaces the request of URL, Method, Headers and Body with its steps if it is set
+	Scenario *Scenario
+	// Mix replaces the request of URL, Method, Headers and Body with a request picked by the weights in each iteration if it is set
+	Mix *Mix
+	// Seed makes the random choices of the workers repeatable if it is not 0, e.g. the requests of the mix
+	Seed int64
+	// ThinkTime is the pause between two iterations of a worker, it is not counted in the latency
+	ThinkTime ThinkTime
+	// Pacing is the minimum period of the iterations of a worker
+	Pacing time.Duration
+	// Interval is the size of the buckets of the timeline, PrintTimeline prints them at the end
+	Interval      time.Duration
+	PrintTimeline bool
+	// Percentiles of the latency to report, e.g. 99.9 for p99.9
+	Percentiles []float64
+	// ExactLatency keeps every latency, otherwise they are recorded in histograms of HistogramPrecision significant digits, 3 by default
+	ExactLatency       bool
+	HistogramPrecision int
+	// LatencyMode is LatencyBody to measure until the last byte of the body, or LatencyHeaders until the headers arrive
+	LatencyMode string
 	Timeout     time.Duration
 	// KeepAlive   bool
 	URL        string
@@ -24,17 +64,23 @@ type TaskDef struct {
 	Body       string
 	TimeUnit   string
 	DisableBar bool
-	PrintError bool
-	Insecure   bool
+	// DisableReport suppresses printing the configuration and the report, the result is still available from Plan.Result
+	DisableReport bool
+	PrintError    bool
+	Insecure      bool
 	// AssertStatusCodes    []int
 	// AssertJSONExpression string
 }
 
 const (
-	NanoSecond         string = "ns"
-	MicroSecond        string = "mms"
-	MilliSecond        string = "ms"
-	Second             string = "s"
+	NanoSecond  string = "ns"
+	MicroSecond string = "mms"
+	MilliSecond string = "ms"
+	Second      string = "s"
+	// Auto picks the time unit of the report by the median latency
+	Auto               string = "auto"
+	LatencyHeaders     string = "headers"
+	LatencyBody        string = "body"
 	NanoSecondDivisor  int64  = 1
 	MicroSecondDivisor int64  = 1000 * NanoSecondDivisor
 	MilliSecondDivisor int64  = 1000 * MicroSecondDivisor
@@ -44,14 +90,40 @@ const (
 // Summary for single http request
 type Summary struct {
 	// StartTimeOfAll  time.Time
-	StartTime time.Time
-	EndTime   time.Time
+	// ScheduledTime is only set in the open model
+	ScheduledTime time.Time
+	StartTime     time.Time
+	// EndTime is StartTime plus TimeToHeaders or TimeToLastByte, depending on the latency mode
+	EndTime        time.Time
+	TimeToHeaders  time.Duration
+	TimeToLastByte time.Duration
 	// EndTimeOfAll    time.Time
+	WorkerID        int
 	StatusCode      int
 	Success         bool
 	FailedAssertion string
 	FailedCause     string
 	HasError        bool
+	// Late is true if the request was sent after its scheduled time because no worker was free
+	Late bool
+	// Dropped is true if the request was never sent because no worker was free
+	Dropped bool
+	// Step is the name of the step of the scenario, it is empty for the summary of a whole iteration
+	Step string
+	// Endpoint is the name of the request of the mix
+	Endpoint string
+	// Phases is the time spent in DNS, connect, TLS, TTFB and download, ConnReused is true if no new connection was made
+	Phases     Phases
+	ConnReused bool
+}
+
+// Latency is the time from StartTime to EndTime, or from ScheduledTime in the open model,
+// so that the time an arrival waits for a free worker is counted as well
+func (s Summary) Latency() time.Duration {
+	if !s.ScheduledTime.IsZero() {
+		return s.EndTime.Sub(s.ScheduledTime)
+	}
+	return s.EndTime.Sub(s.StartTime)
 }
 
 type Worker struct {
@@ -63,15 +135,38 @@ type Worker struct {
 	Assertions []Assertion
 	// reusedTransport http.RoundTripper
 	httpClient *http.Client
+	// budget is shared by the workers if the total number of requests is fixed
+	budget *int64
+	rnd    *rand.Rand
+	// vars are the variables of the virtual user, set by the data and the extractors of the scenario, and kept across the iterations
+	vars map[string]string
+	// record is the record of the data kept by the virtual user in the unique mode
+	record map[string]string
+	// seq is the sequence of the templates, shared by the workers of the plan
+	seq *int64
+	// iteration counts the iterations of the worker, for the templates
+	iteration int
+	// templates are the parsed templates of the requests, by their source
+	templates map[string]*template.Template
 }
 
-func (w *Worker) StartLoop(wg *sync.WaitGroup, summaryChannel chan Summary) {
+func (w *Worker) StartLoop(ctx context.Context, wg *sync.WaitGroup, summaryChannel chan Summary) {
 	// t0 := time.Now()
 	defer wg.Done()
 	w.initClient()
 	// var costOfPreSending, costOfSending, costOfPostSending, costOfWritingChannel int64
-	for i := 0; i < w.TaskDef.Loop; i++ {
-		w.doRequest(wg, summaryChannel)
+	var lastStart time.Time
+	for i := 0; w.hasNext(ctx, i); i++ {
+		// pause before the next iteration instead of after each one, so that the run does not end with a pause
+		if i > 0 && !w.pause(ctx, lastStart) {
+			break
+		}
+		// the record is taken before the budget, so that a worker without any record leaves its share to the others
+		if !w.feed() || !w.take() {
+			break
+		}
+		lastStart = time.Now()
+		w.doIteration(ctx, wg, summaryChannel, Summary{})
 		// costOfPreSending += c1
 		// costOfSending += c2
 		// costOfPostSending += c3
@@ -82,6 +177,38 @@ func (w *Worker) StartLoop(wg *sync.WaitGroup, summaryChannel chan Summary) {
 	// w.WorkerStopChannel <- w.ID
 }
 
+// StartServing sends a request for each arrival until the arrivals channel is closed,
+// or until the data is exhausted, then the arrival is dropped and the next ones are left to the other workers
+func (w *Worker) StartServing(ctx context.Context, wg *sync.WaitGroup, arrivals chan arrival, summaryChannel chan Summary) {
+	defer wg.Done()
+	w.initClient()
+	for a := range arrivals {
+		if !w.feed() {
+			summaryChannel <- droppedAt(a.scheduled)
+			return
+		}
+		w.doIteration(ctx, wg, summaryChannel, Summary{ScheduledTime: a.scheduled, Late: a.late})
+	}
+}
+
+// hasNext reports whether the worker should start its i-th iteration
+func (w *Worker) hasNext(ctx context.Context, i int) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+	if w.budget != nil || w.TaskDef.Duration > 0 {
+		return true
+	}
+	return i < w.TaskDef.Loop
+}
+
+// take takes a request from the budget shared by the workers, it returns false if there is none left
+func (w *Worker) take() bool {
+	return w.budget == nil || atomic.AddInt64(w.budget, -1) >= 0
+}
+
 func (w *Worker) initClient() {
 	var dialKeepAlive time.Duration
 	// if w.TaskDef.KeepAlive {
@@ -116,18 +243,74 @@ func (w *Worker) initClient() {
 	w.httpClient = &http.Client{Timeout: timeout, Transport: reusedTransport}
 }
 
-func (w *Worker) doRequest(wg *sync.WaitGroup, summaryChannel chan Summary) {
-	summary := Summary{}
-	req, err := http.NewRequest(w.TaskDef.Method, w.TaskDef.URL, bytes.NewBuffer([]byte(w.TaskDef.Body)))
+// request is what a worker sends, it comes from the TaskDef or a step of the scenario
+type request struct {
+	method  string
+	url     string
+	headers []string
+	body    string
+	// timeout overrides the one of the client if it is set
+	timeout time.Duration
+}
+
+// doIteration runs the scenario if there is one, or sends the request of the TaskDef otherwise
+func (w *Worker) doIteration(ctx context.Context, wg *sync.WaitGroup, summaryChannel chan Summary, summary Summary) {
+	w.iteration++
+	if w.TaskDef.Scenario != nil {
+		w.runScenario(ctx, summaryChannel, summary)
+		return
+	}
+	if w.TaskDef.Mix != nil {
+		w.doMixRequest(summaryChannel, summary)
+		return
+	}
+	w.doRequest(wg, summaryChannel, summary)
+}
+
+func (w *Worker) doRequest(wg *sync.WaitGroup, summaryChannel chan Summary, summary Summary) {
+	summary, resp := w.send(request{
+		method:  w.TaskDef.Method,
+		url:     w.TaskDef.URL,
+		headers: w.TaskDef.Headers,
+		body:    w.TaskDef.Body,
+	}, summary)
+	if resp == nil {
+		summaryChannel <- summary
+		return
+	}
+	wg.Add(1)
+	go w.verifyAllAssertions(*resp, wg, &summary, summaryChannel)
+}
+
+// send renders the request, sends it and reads the whole body,
+// the response is nil if the request or the body failed, and the cause is in the summary
+func (w *Worker) send(r request, summary Summary) (Summary, *HttpResponse) {
+	summary.WorkerID = w.ID
+	r, err := w.renderRequest(r)
+	var req *http.Request
+	if err == nil {
+		req, err = http.NewRequest(r.method, r.url, bytes.NewBuffer([]byte(r.body)))
+	}
+	if err != nil {
+		if w.TaskDef.PrintError {
+			log.Printf("error: %s\n", err)
+		}
+		summary.StartTime = time.Now()
+		summary.EndTime = summary.StartTime
+		summary.HasError = true
+		summary.FailedCause = err.Error()
+		return summary, nil
+	}
 	// req.Header.Add("Connection", "keep-alive")
 	// log.Printf("%+v", w.TaskDef.Headers)
-	if len(w.TaskDef.Headers) > 0 {
+	if len(r.headers) > 0 {
 		// log.Printf("len of headers: %d, headers: %+v\n", len(w.TaskDef.Headers), w.TaskDef.Headers)
-		for _, header := range w.TaskDef.Headers {
+		for _, header := range r.headers {
 			if strings.Trim(header, " ") == "" {
 				continue
 			}
-			pair := strings.Split(header, ":")
+			// only the first colon separates the name, the value may have colons as well, e.g. a url or a time
+			pair := strings.SplitN(header, ":", 2)
 			key := strings.Trim(pair[0], " ")
 			if key == "" {
 				continue
@@ -136,55 +319,77 @@ func (w *Worker) doRequest(wg *sync.WaitGroup, summaryChannel chan Summary) {
 			if len(pair) < 2 {
 				value = ""
 			} else {
-				value = pair[1]
+				value = strings.TrimSpace(pair[1])
+			}
+			// the Host header is ignored by the client, it is sent by req.Host
+			if strings.EqualFold(key, "Host") {
+				req.Host = value
+				continue
 			}
 			req.Header.Add(key, value)
 		}
 	}
+	client := w.httpClient
+	if r.timeout > 0 {
+		c := *w.httpClient
+		c.Timeout = r.timeout
+		client = &c
+	}
+	trace := &phaseTrace{}
+	req = withTrace(req, trace)
 	summary.StartTime = time.Now()
-	resp, err := w.httpClient.Do(req)
-	summary.EndTime = time.Now()
+	resp, err := client.Do(req)
+	headersTime := time.Now()
+	summary.EndTime = headersTime
+	summary.TimeToHeaders = headersTime.Sub(summary.StartTime)
+	summary.Phases, summary.ConnReused = trace.phases()
 	if err != nil {
 		// panic(err)
 		if w.TaskDef.PrintError {
 			log.Printf("error: %s\n", err)
 		}
 		summary.HasError = true
-		summaryChannel <- summary
-		return
+		summary.FailedCause = err.Error()
+		return summary, nil
 	}
-	wg.Add(1)
-	go w.verifyAllAssertions(resp, wg, &summary, summaryChannel)
-	return
-}
-
-// verifyAllAssertions returns success, assertionName, cause
-func (w *Worker) verifyAllAssertions(resp *http.Response, wg *sync.WaitGroup, summary *Summary, summaryChannel chan Summary) {
-	// log.Println("111")
-	defer wg.Done()
-	// log.Printf("%+v\n", resp)
-	// t0 := time.Now()
+	// the body is read by the worker, so that a slow body holds the worker as it holds a real client
 	body, err := ioutil.ReadAll(resp.Body)
-	// t1 := time.Now()
 	resp.Body.Close()
+	lastByteTime := time.Now()
+	summary.TimeToLastByte = lastByteTime.Sub(summary.StartTime)
+	summary.Phases.Download = lastByteTime.Sub(headersTime)
+	if w.TaskDef.LatencyMode != LatencyHeaders {
+		summary.EndTime = lastByteTime
+	}
 	if err != nil {
+		// the connection failed in the middle of the body, it is an error rather than a failed assertion
+		if w.TaskDef.PrintError {
+			log.Printf("error: %s\n", err)
+		}
+		summary.HasError = true
 		summary.Success = false
 		summary.FailedAssertion = ""
 		summary.FailedCause = err.Error()
-		summaryChannel <- *summary
-		return
+		return summary, nil
 	}
-	httpResponse := HttpResponse{
+	return summary, &HttpResponse{
 		Status:     resp.Status,
 		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
 		Body:       body,
 	}
-	if len(w.Assertions) == 0 {
-		summary.Success = true
-		summaryChannel <- *summary
-		return
-	}
-	for _, a := range w.Assertions {
+}
+
+// verifyAllAssertions verifies the assertions of the plan and sends the summary
+func (w *Worker) verifyAllAssertions(httpResponse HttpResponse, wg *sync.WaitGroup, summary *Summary, summaryChannel chan Summary) {
+	defer wg.Done()
+	w.verify(w.Assertions, httpResponse, summary)
+	summaryChannel <- *summary
+}
+
+// verify sets success, assertionName and cause of the summary by the first assertion which fails
+func (w *Worker) verify(assertions []Assertion, httpResponse HttpResponse, summary *Summary) {
+	for _, a := range assertions {
 		if a == nil {
 			continue
 		}
@@ -192,17 +397,13 @@ func (w *Worker) verifyAllAssertions(resp *http.Response, wg *sync.WaitGroup, su
 			summary.Success = false
 			summary.FailedAssertion = a.Name()
 			summary.FailedCause = cause
-			summaryChannel <- *summary
 			if w.TaskDef.PrintError {
 				log.Printf("Assertion Failed, Caused by: %s, %s\n", summary.FailedAssertion, summary.FailedCause)
 			}
 			return
 		}
 	}
-	// t2 := time.Now()
-	// log.Printf("reading body: %d ms, asserting: %d ms", t1.Sub(t0).Milliseconds(), t2.Sub(t1).Milliseconds())
 	summary.Success = true
-	summaryChannel <- *summary
 }
 
 var neverReusedTransport http.RoundTripper = &http.Transport{
@@ -257,10 +458,79 @@ var neverReusedTransport http.RoundTripper = &http.Transport{
 func (d TaskDef) PrintToStdOut() {
 	fmt.Println("-- Configuration --")
 	fmt.Printf("Concurrency: %d\t", d.Concurrency)
-	fmt.Printf("Loop: %d\t", d.Loop)
+	if d.Rate > 0 {
+		fmt.Printf("Rate: %g/s\t", d.Rate)
+	}
+	if len(d.Stages) > 0 {
+		fmt.Printf("Stages: ")
+		for i, stage := range d.Stages {
+			if i > 0 {
+				fmt.Printf(",")
+			}
+			fmt.Printf("%s:%d", stage.Duration, stage.Target)
+		}
+		fmt.Printf("\t")
+	}
+	if d.Adaptive.Enabled() {
+		fmt.Printf("Adaptive: %s p%g<=%s\t", d.Adaptive.Controller, d.Adaptive.Percentile, d.Adaptive.TargetLatency)
+	}
+	if d.Requests > 0 {
+		fmt.Printf("Requests: %d\t", d.Requests)
+	}
+	if d.Warmup > 0 {
+		fmt.Printf("Warmup: %s\t", d.Warmup)
+	} else if d.WarmupRequests > 0 {
+		fmt.Printf("Warmup: %d requests\t", d.WarmupRequests)
+	}
+	if d.ThinkTime != nil {
+		fmt.Printf("ThinkTime: %s\t", d.ThinkTime)
+	}
+	if d.Pacing > 0 {
+		fmt.Printf("Pacing: %s\t", d.Pacing)
+	}
+	if d.Duration > 0 {
+		fmt.Printf("Duration: %s\t", d.Duration)
+	} else if d.Requests == 0 {
+		fmt.Printf("Loop: %d\t", d.Loop)
+	}
 	fmt.Printf("Timeout: %d ms\t", d.Timeout.Milliseconds())
 	// fmt.Printf("KeepAlive: %t\t", d.KeepAlive)
 	fmt.Printf("TimeUnit: %s\t", d.TimeUnit)
+	if d.LatencyMode != "" {
+		fmt.Printf("LatencyMode: %s\t", d.LatencyMode)
+	}
+	if d.Data != nil {
+		fmt.Printf("Data: %s\n", d.Data)
+	}
+	if d.Scenario != nil {
+		if d.Scenario.Name != "" {
+			fmt.Printf("Scenario: %s\n", d.Scenario.Name)
+		} else if d.Data == nil {
+			// the steps start on their own line
+			fmt.Println()
+		}
+		for i, step := range d.Scenario.Steps {
+			if step.Delay > 0 {
+				fmt.Printf("  %d. %s: %s %s after %s\n", i+1, step.Name, step.Method, step.URL, step.Delay)
+			} else {
+				fmt.Printf("  %d. %s: %s %s\n", i+1, step.Name, step.Method, step.URL)
+			}
+		}
+		fmt.Println()
+		return
+	}
+	if d.Mix != nil {
+		if d.Mix.Name != "" {
+			fmt.Printf("Mix: %s\n", d.Mix.Name)
+		} else if d.Data == nil {
+			fmt.Println()
+		}
+		for i, r := range d.Mix.Requests {
+			fmt.Printf("  %.1f%% %s: %s %s\n", d.Mix.Share(i), r.Name, r.Method, r.URL)
+		}
+		fmt.Println()
+		return
+	}
 	fmt.Printf("Method: %s\t", d.Method)
 	fmt.Printf("URL: %s\n", d.URL)
 	fmt.Printf("Headers: %s\n", d.Headers)
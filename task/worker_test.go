@@ -0,0 +1,70 @@
+package task
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendHeaders(t *testing.T) {
+	ast := assert.New(t)
+	var received http.Header
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+		host = r.Host
+	}))
+	defer server.Close()
+	w := &Worker{}
+	w.initClient()
+	summary, resp := w.send(request{
+		method: http.MethodGet,
+		url:    server.URL,
+		headers: []string{
+			"Referer: http://localhost:1234/users?at=12:30",
+			"X-Empty",
+			"  ",
+			"Host: example.com",
+			"X-Multi: a",
+			"X-Multi: b",
+		},
+	}, Summary{})
+	ast.NotNil(resp)
+	ast.False(summary.HasError)
+	ast.Equal("http://localhost:1234/users?at=12:30", received.Get("Referer"))
+	ast.Equal([]string{""}, received.Values("X-Empty"))
+	ast.Equal([]string{"a", "b"}, received.Values("X-Multi"))
+	ast.Equal("example.com", host)
+}
+
+func TestSendBodyCutOff(t *testing.T) {
+	ast := assert.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("only a part"))
+		w.(http.Flusher).Flush()
+		// drop the connection in the middle of the body
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+	w := &Worker{}
+	w.initClient()
+	summary, resp := w.send(request{method: http.MethodGet, url: server.URL}, Summary{})
+	ast.Nil(resp)
+	ast.True(summary.HasError)
+	ast.False(summary.Success)
+	ast.NotEmpty(summary.FailedCause)
+
+	l := BuildSimpleListener(0, "ms")
+	l.OnStart()
+	l.OnRequestFinished(summary)
+	l.OnPlanFinished()
+	ast.Equal(1, l.errorCount)
+	ast.Zero(l.failedLatency.Count())
+}